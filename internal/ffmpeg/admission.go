@@ -0,0 +1,116 @@
+package ffmpeg
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Voodfy/voodfy-transcoder/internal/models"
+	"github.com/Voodfy/voodfy-transcoder/internal/settings"
+)
+
+// ErrRejected returned when the source breaks a rule of the admission
+var ErrRejected = errors.New("ffmpeg: source rejected")
+
+// Admit probe the source and apply the rules of the admission setting, the first seconds
+// are decoded when the probe passes, a source that can't be probed or decoded is rejected
+func Admit(ctx context.Context, filename string) (models.MediaInfo, error) {
+	cfg := settings.AdmissionSetting
+
+	info, err := Probe(ctx, filename)
+	if err != nil {
+		return info, fmt.Errorf("%w: %s can't be probed: %v", ErrRejected, filename, err)
+	}
+
+	if err := CheckAdmission(info, cfg); err != nil {
+		return info, fmt.Errorf("%w: %s %v", ErrRejected, filename, err)
+	}
+
+	if !cfg.Decode {
+		return info, nil
+	}
+
+	var stderr bytes.Buffer
+	err = execute(ctx, nil, &stderr, "ffmpeg", "-hide_banner", "-nostats", "-v", "error",
+		"-t", fmt.Sprintf("%g", cfg.DecodeDuration), "-i", filename,
+		"-map", fmt.Sprintf("0:%d", info.Video.Index), "-map", "0:a?", "-f", "null", "-")
+	if err != nil {
+		// a decoder failing isn't a reason to retry, unless the check was canceled
+		if ctx.Err() != nil {
+			return info, err
+		}
+		return info, fmt.Errorf("%w: %s decoding: %v", ErrRejected, filename, err)
+	}
+	if lines := strings.TrimSpace(stderr.String()); lines != "" {
+		return info, fmt.Errorf("%w: %s decoding: %s", ErrRejected, filename, strings.SplitN(lines, "\n", 2)[0])
+	}
+
+	return info, nil
+}
+
+// CheckAdmission return the first rule of the setting broken by the source
+func CheckAdmission(info models.MediaInfo, cfg *settings.Admission) error {
+	if cfg.MinProbeScore > 0 && info.ProbeScore < cfg.MinProbeScore {
+		return fmt.Errorf("probe score is %d, minimum %d", info.ProbeScore, cfg.MinProbeScore)
+	}
+
+	if !allowed(cfg.Containers, strings.Split(info.FormatName, ",")...) {
+		return fmt.Errorf("container %s isn't allowed", info.FormatName)
+	}
+
+	if limit := time.Duration(cfg.MaxDuration * float64(time.Second)); limit > 0 && info.Duration > limit {
+		return fmt.Errorf("duration is %s, maximum %s", info.Duration, limit)
+	}
+
+	if limit := cfg.MaxSize << 20; limit > 0 && info.Size > limit {
+		return fmt.Errorf("size is %dMB, maximum %dMB", info.Size>>20, cfg.MaxSize)
+	}
+
+	v := info.Video
+	if v == nil {
+		return errors.New("without video stream")
+	}
+
+	if !allowed(cfg.VideoCodecs, v.Codec) {
+		return fmt.Errorf("video codec %s isn't allowed", v.Codec)
+	}
+
+	// the limits are given to the landscape orientation
+	long, short := v.DisplayWidth, v.DisplayHeight
+	if long < short {
+		long, short = short, long
+	}
+	if (cfg.MaxWidth > 0 && long > cfg.MaxWidth) || (cfg.MaxHeight > 0 && short > cfg.MaxHeight) {
+		return fmt.Errorf("resolution is %dx%d, maximum %dx%d", v.DisplayWidth, v.DisplayHeight, cfg.MaxWidth, cfg.MaxHeight)
+	}
+
+	for _, a := range info.Audios {
+		if !allowed(cfg.AudioCodecs, a.Codec) {
+			return fmt.Errorf("audio codec %s of the stream %d isn't allowed", a.Codec, a.Index)
+		}
+		if a.Channels == 0 || a.SampleRate == 0 {
+			return fmt.Errorf("audio stream %d without channels or sample rate", a.Index)
+		}
+	}
+
+	return nil
+}
+
+// allowed return true when the list is empty or holds one of the values
+func allowed(list []string, values ...string) bool {
+	if len(list) == 0 {
+		return true
+	}
+
+	for _, item := range list {
+		for _, v := range values {
+			if item == v {
+				return true
+			}
+		}
+	}
+	return false
+}
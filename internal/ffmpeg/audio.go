@@ -0,0 +1,119 @@
+package ffmpeg
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/Voodfy/voodfy-transcoder/internal/models"
+	"github.com/Voodfy/voodfy-transcoder/internal/settings"
+)
+
+// UndefinedLanguage iso 639-2 code used when the stream doesn't declare its language
+const UndefinedLanguage = "und"
+
+// TranscodeAudio generate the audio rendition of the stream described by the profile, normalized by
+// the loudness measured to the stream when it is given, the progress is sent to the reporter
+func (c *Client) TranscodeAudio(ctx context.Context, filename, dstFile string, p settings.AudioProfile, s models.AudioStream, report Reporter) error {
+	return runWithProgress(ctx, report, "ffmpeg", AudioArgs(filename, dstFile, p, s)...)
+}
+
+// AudioArgs return the ffmpeg arguments of the audio profile, the stream of the source is encoded
+// whatever its codec so ac3, pcm or vorbis sources are supported, its language is kept on the output
+func AudioArgs(filename, dstFile string, p settings.AudioProfile, s models.AudioStream) []string {
+	args := []string{"-hide_banner", "-y", "-i", filename, "-map", fmt.Sprintf("0:a:%d", s.Index), "-vn"}
+
+	// loudnorm resamples to 192kHz, 48kHz is used when the profile doesn't set the sample rate
+	if filter := LoudnormFilter(s.Loudness); filter != "" {
+		args = append(args, "-af", filter)
+		if p.SampleRate == 0 {
+			args = append(args, "-ar", "48000")
+		}
+	}
+
+	args = append(args, "-c:a", p.Codec)
+
+	if p.Bitrate != "" {
+		args = append(args, "-b:a", p.Bitrate)
+	}
+
+	if p.Channels > 0 {
+		args = append(args, "-ac", strconv.Itoa(p.Channels))
+	}
+
+	if p.SampleRate > 0 {
+		args = append(args, "-ar", strconv.Itoa(p.SampleRate))
+	}
+
+	if s.Language != "" {
+		args = append(args, "-metadata:s:a:0", fmt.Sprintf("language=%s", s.Language))
+	}
+
+	// ffmpeg 4.1 only writes opus on mp4 as experimental
+	if p.Codec == "libopus" {
+		args = append(args, "-strict", "experimental")
+	}
+
+	return append(args, "-movflags", "faststart", "-f", "mp4", dstFile)
+}
+
+// audioRendition transcode the stream given by the fifth argument with the audio profile planned
+// to the job, the profile registered on the audio ladder is used without job
+func audioRendition(ctx context.Context, cmd Commands, args ...string) error {
+	var job models.Job
+	if len(args) > 3 {
+		job.ID = args[3]
+		job.Get()
+	}
+
+	p, ok := job.AudioProfile(args[2])
+	if !ok {
+		p, ok = settings.AudioLadderSetting.Profile(args[2])
+	}
+	if !ok {
+		return fmt.Errorf("ffmpeg: unknown audio rendition %s", args[2])
+	}
+
+	s := models.AudioStream{Language: UndefinedLanguage, Default: true}
+	if len(args) > 4 {
+		index, err := strconv.Atoi(args[4])
+		if err != nil {
+			return fmt.Errorf("ffmpeg: invalid audio stream %s: %w", args[4], err)
+		}
+		s.Index = index
+		if planned, found := job.AudioStream(index); found {
+			s = planned
+		}
+	}
+
+	return cmd.TranscodeAudio(ctx, args[0], args[1], p, s, reporter(ctx, fmt.Sprintf("%s_%d", p.Name, s.Index), args...))
+}
+
+// AudioStreams return the audio streams of the source with their language and disposition,
+// the first one is the default when the source doesn't flag any
+func AudioStreams(info models.MediaInfo) []models.AudioStream {
+	var streams []models.AudioStream
+	var hasDefault bool
+
+	for _, a := range info.Audios {
+		s := models.AudioStream{
+			Index:       a.Index,
+			Language:    a.Language,
+			Default:     a.Default && !hasDefault,
+			Commentary:  a.Commentary,
+			Descriptive: a.Descriptive,
+		}
+		if s.Language == "" {
+			s.Language = UndefinedLanguage
+		}
+		hasDefault = hasDefault || s.Default
+
+		streams = append(streams, s)
+	}
+
+	if !hasDefault && len(streams) > 0 {
+		streams[0].Default = true
+	}
+
+	return streams
+}
@@ -0,0 +1,164 @@
+package ffmpeg
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/Voodfy/voodfy-transcoder/internal/settings"
+)
+
+// bifMagic first bytes of every bif archive
+var bifMagic = []byte{0x89, 0x42, 0x49, 0x46, 0x0d, 0x0a, 0x1a, 0x0a}
+
+const (
+	// bifHeaderSize magic, version, number of images, timestamp multiplier and the reserved bytes
+	bifHeaderSize = 64
+	// bifIndexEnd timestamp of the last entry of the index that points to the end of the last image
+	bifIndexEnd = 0xffffffff
+)
+
+// bifSize size of the thumbnails of a bif archive
+type bifSize struct {
+	name          string
+	width, height int
+}
+
+// BIF generate the roku trick-play archives at SD and HD sizes on the output directory, the
+// frames of both sizes are extracted by a single decode of the source
+func (c *Client) BIF(ctx context.Context, filename, dstFile, resourceID string) error {
+	cfg := settings.BIFSetting
+	sizes := []bifSize{
+		{"sd", cfg.SDWidth, cfg.SDHeight},
+		{"hd", cfg.HDWidth, cfg.HDHeight},
+	}
+
+	tmp, err := ioutil.TempDir("", "bif_")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+
+	interval := settings.ThumbnailsSetting.Interval
+	args := []string{"-hide_banner", "-y", "-i", filename, "-filter_complex", bifFilter(interval, sizes)}
+	for _, s := range sizes {
+		dir := filepath.Join(tmp, s.name)
+		if err := os.Mkdir(dir, 0755); err != nil {
+			return err
+		}
+		args = append(args, "-map", fmt.Sprintf("[%s]", s.name), "-vsync", "vfr", "-q:v", "5", filepath.Join(dir, "frame_%05d.jpg"))
+	}
+
+	if err := runCommand(ctx, nil, "ffmpeg", args...); err != nil {
+		return err
+	}
+
+	for _, s := range sizes {
+		frames, err := filepath.Glob(filepath.Join(tmp, s.name, "frame_*.jpg"))
+		if err != nil {
+			return err
+		}
+		sort.Strings(frames)
+
+		dst := filepath.Join(dstFile, fmt.Sprintf("%s_%s.bif", resourceID, s.name))
+		if err := writeBIFFile(dst, frames, time.Duration(interval*float64(time.Second))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// bifFilter return the filter graph that takes a frame each interval and splits it to the
+// thumbnails fitted on each size, labeled by the name of the size
+func bifFilter(interval float64, sizes []bifSize) string {
+	graph := fmt.Sprintf("[0:v]fps=1/%g,split=%d", interval, len(sizes))
+	for idx := range sizes {
+		graph += fmt.Sprintf("[f%d]", idx)
+	}
+
+	for idx, s := range sizes {
+		graph += fmt.Sprintf(";[f%d]scale=%d:%d:force_original_aspect_ratio=decrease,pad=%d:%d:(ow-iw)/2:(oh-ih)/2[%s]",
+			idx, s.width, s.height, s.width, s.height, s.name)
+	}
+	return graph
+}
+
+// writeBIFFile write the frames on the bif archive
+func writeBIFFile(dstFile string, frames []string, interval time.Duration) error {
+	f, err := os.Create(dstFile)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+	if err := WriteBIF(w, frames, interval); err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+// WriteBIF write the header, the index table and the jpegs of the frames, the timestamp of the
+// frame n is n times the interval
+func WriteBIF(w io.Writer, frames []string, interval time.Duration) error {
+	sizes := make([]int64, len(frames))
+	for idx, frame := range frames {
+		info, err := os.Stat(frame)
+		if err != nil {
+			return err
+		}
+		sizes[idx] = info.Size()
+	}
+
+	header := make([]byte, bifHeaderSize)
+	copy(header, bifMagic)
+	binary.LittleEndian.PutUint32(header[8:], 0)
+	binary.LittleEndian.PutUint32(header[12:], uint32(len(frames)))
+	binary.LittleEndian.PutUint32(header[16:], uint32(interval/time.Millisecond))
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+
+	offset := int64(bifHeaderSize + (len(frames)+1)*8)
+	entry := make([]byte, 8)
+	for idx, size := range sizes {
+		binary.LittleEndian.PutUint32(entry, uint32(idx))
+		binary.LittleEndian.PutUint32(entry[4:], uint32(offset))
+		if _, err := w.Write(entry); err != nil {
+			return err
+		}
+		offset += size
+	}
+
+	binary.LittleEndian.PutUint32(entry, bifIndexEnd)
+	binary.LittleEndian.PutUint32(entry[4:], uint32(offset))
+	if _, err := w.Write(entry); err != nil {
+		return err
+	}
+
+	for _, frame := range frames {
+		if err := copyFile(w, frame); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// copyFile write the content of the file on the writer
+func copyFile(w io.Writer, filename string) error {
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = io.Copy(w, f)
+	return err
+}
@@ -0,0 +1,115 @@
+package ffmpeg
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWriteBIF(t *testing.T) {
+	tests := []struct {
+		name     string
+		frames   []string
+		interval time.Duration
+	}{
+		{
+			name:     "several frames",
+			frames:   []string{"first", "second frame", "3"},
+			interval: 10 * time.Second,
+		},
+		{
+			name:     "single frame",
+			frames:   []string{"only frame"},
+			interval: 2500 * time.Millisecond,
+		},
+		{
+			name:     "without frames",
+			interval: time.Second,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "bif_")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+
+			// each frame holds its content as the jpeg
+			var frames []string
+			for idx, content := range tt.frames {
+				frame := filepath.Join(dir, fmt.Sprintf("frame_%05d.jpg", idx+1))
+				if err := ioutil.WriteFile(frame, []byte(content), 0644); err != nil {
+					t.Fatal(err)
+				}
+				frames = append(frames, frame)
+			}
+
+			var buf bytes.Buffer
+			if err := WriteBIF(&buf, frames, tt.interval); err != nil {
+				t.Fatal(err)
+			}
+			b := buf.Bytes()
+
+			index := bifHeaderSize + (len(tt.frames)+1)*8
+			if len(b) < index {
+				t.Fatalf("expected at least %d bytes, got %d", index, len(b))
+			}
+
+			if !bytes.Equal(b[:8], bifMagic) {
+				t.Errorf("expected the magic %x, got %x", bifMagic, b[:8])
+			}
+			if version := binary.LittleEndian.Uint32(b[8:]); version != 0 {
+				t.Errorf("expected the version 0, got %d", version)
+			}
+			if count := binary.LittleEndian.Uint32(b[12:]); count != uint32(len(tt.frames)) {
+				t.Errorf("expected %d images, got %d", len(tt.frames), count)
+			}
+			if ms := binary.LittleEndian.Uint32(b[16:]); ms != uint32(tt.interval/time.Millisecond) {
+				t.Errorf("expected the interval of %d ms, got %d", tt.interval/time.Millisecond, ms)
+			}
+			if reserved := b[20:bifHeaderSize]; !bytes.Equal(reserved, make([]byte, len(reserved))) {
+				t.Errorf("expected the reserved bytes zeroed, got %x", reserved)
+			}
+
+			offset := uint32(index)
+			for idx, content := range tt.frames {
+				entry := b[bifHeaderSize+idx*8:]
+				if ts := binary.LittleEndian.Uint32(entry); ts != uint32(idx) {
+					t.Errorf("entry %d: expected the timestamp %d, got %d", idx, idx, ts)
+				}
+				if o := binary.LittleEndian.Uint32(entry[4:]); o != offset {
+					t.Errorf("entry %d: expected the offset %d, got %d", idx, offset, o)
+				}
+				if got := string(b[offset : offset+uint32(len(content))]); got != content {
+					t.Errorf("entry %d: expected the image %q, got %q", idx, content, got)
+				}
+				offset += uint32(len(content))
+			}
+
+			end := b[bifHeaderSize+len(tt.frames)*8:]
+			if ts := binary.LittleEndian.Uint32(end); ts != bifIndexEnd {
+				t.Errorf("expected the terminator %x, got %x", bifIndexEnd, ts)
+			}
+			if o := binary.LittleEndian.Uint32(end[4:]); o != offset || int(o) != len(b) {
+				t.Errorf("expected the end offset %d of %d bytes, got %d", offset, len(b), o)
+			}
+		})
+	}
+}
+
+func TestWriteBIFMissingFrame(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteBIF(&buf, []string{filepath.Join(os.TempDir(), "bif_missing", "frame_00001.jpg")}, time.Second); err == nil {
+		t.Fatal("expected an error of the missing frame")
+	}
+	if buf.Len() != 0 {
+		t.Errorf("expected nothing written, got %d bytes", buf.Len())
+	}
+}
@@ -0,0 +1,130 @@
+package ffmpeg
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Voodfy/voodfy-transcoder/internal/models"
+	"github.com/Voodfy/voodfy-transcoder/internal/settings"
+)
+
+// PlanChunks cut the source at the first keyframe after each length, the keyframes are made
+// relative to the start of the source and a last chunk shorter than half length is joined
+// to the previous one
+func PlanChunks(keyframes []float64, info models.MediaInfo, length float64) []models.Chunk {
+	duration, offset := info.Duration.Seconds(), info.StartTime.Seconds()
+
+	var chunks []models.Chunk
+	var start float64
+	for _, k := range keyframes {
+		k -= offset
+		if k-start >= length && duration-k >= length/2 {
+			chunks = append(chunks, models.Chunk{Index: len(chunks), Start: start, End: k})
+			start = k
+		}
+	}
+
+	return append(chunks, models.Chunk{Index: len(chunks), Start: start, End: duration})
+}
+
+// ChunkFile return the file of the chunk of the rendition on the directory of the chunks,
+// named <rendition name>_<index>.<extension>
+func ChunkFile(dir, output string, index int) string {
+	ext := filepath.Ext(output)
+	name := strings.TrimSuffix(filepath.Base(output), ext)
+	return filepath.Join(dir, fmt.Sprintf("%s_%03d%s", name, index, ext))
+}
+
+// TranscodeChunk generate the part of the rendition between the start and the end of the chunk
+func (c *Client) TranscodeChunk(ctx context.Context, filename, dstFile string, p settings.RenditionProfile, chunk models.Chunk, report Reporter) error {
+	return encode(ctx, report, p, ChunkArgs(filename, dstFile, p, chunk))
+}
+
+// ChunkArgs return the arguments of the rendition seeking the input to the chunk, the
+// seek before the input decodes from the keyframe the chunk starts on
+func ChunkArgs(filename, dstFile string, p settings.RenditionProfile, chunk models.Chunk) []string {
+	args := []string{"-hide_banner", "-y", "-ss", fmt.Sprintf("%.3f", chunk.Start), "-t", fmt.Sprintf("%.3f", chunk.End-chunk.Start)}
+
+	// RenditionArgs starts with -hide_banner -y
+	return append(args, RenditionArgs(filename, dstFile, p)[2:]...)
+}
+
+// Concat join the files without transcoding them with the concat demuxer
+func (c *Client) Concat(ctx context.Context, files []string, dstFile string) error {
+	list, err := ioutil.TempFile("", "concat_*.txt")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(list.Name())
+
+	for _, f := range files {
+		abs, err := filepath.Abs(f)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(list, "file '%s'\n", strings.Replace(abs, "'", `'\''`, -1))
+	}
+	if err := list.Close(); err != nil {
+		return err
+	}
+
+	args := []string{"-hide_banner", "-y", "-f", "concat", "-safe", "0", "-i", list.Name(), "-c", "copy"}
+	if filepath.Ext(dstFile) == ".mp4" {
+		args = append(args, "-movflags", "faststart")
+	}
+	return runCommand(ctx, nil, "ffmpeg", append(args, dstFile)...)
+}
+
+// chunkRendition transcode the chunk given by the fifth argument with the rendition planned to the job
+func chunkRendition(ctx context.Context, cmd Commands, args ...string) error {
+	p, ok := profile(args[2], args...)
+	if !ok {
+		return fmt.Errorf("ffmpeg: unknown job %s", args[2])
+	}
+
+	job := models.Job{ID: args[3]}
+	job.Get()
+
+	index, err := strconv.Atoi(args[4])
+	if err != nil || index < 0 || index >= len(job.Chunks) {
+		return fmt.Errorf("ffmpeg: unknown chunk %s of %s", args[4], args[3])
+	}
+	chunk := job.Chunks[index]
+
+	duration := time.Duration((chunk.End - chunk.Start) * float64(time.Second))
+	report := StoreProgress(job.ID, fmt.Sprintf("%s_%03d", p.Name, chunk.Index), duration)
+
+	return cmd.TranscodeChunk(ctx, args[0], args[1], p, chunk, report)
+}
+
+// concatRendition join the chunks of the rendition found on the directory given by the fifth
+// argument, the chunks are removed once joined and the directory once empty
+func concatRendition(ctx context.Context, cmd Commands, args ...string) error {
+	job := models.Job{ID: args[3]}
+	job.Get()
+
+	if len(job.Chunks) == 0 {
+		return fmt.Errorf("ffmpeg: %s without chunks", args[3])
+	}
+
+	files := make([]string, len(job.Chunks))
+	for idx := range job.Chunks {
+		files[idx] = ChunkFile(args[4], args[1], idx)
+	}
+
+	if err := cmd.Concat(ctx, files, args[1]); err != nil {
+		return err
+	}
+
+	for _, f := range files {
+		os.Remove(f)
+	}
+	os.Remove(args[4])
+	return nil
+}
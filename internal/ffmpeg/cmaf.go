@@ -0,0 +1,59 @@
+package ffmpeg
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/Voodfy/voodfy-transcoder/internal/settings"
+)
+
+const (
+	// CMAFDir directory inside the output where the cmaf packaging is written
+	CMAFDir = "cmaf"
+
+	// PackagingSegmented mode that write hls ts segments and dash m4s segments
+	PackagingSegmented = "segmented"
+	// PackagingCMAF mode that write one fragmented mp4 per track shared by hls and dash
+	PackagingCMAF = "cmaf"
+)
+
+// PackageCMAF write one fragmented mp4 per track, the byte-range hls playlists
+// and the manifest.mpd point to the same files
+func (c *Client) PackageCMAF(ctx context.Context, dir, resourceID string) error {
+	dst := filepath.Join(dir, CMAFDir)
+	os.MkdirAll(dst, 0777)
+
+	videos, audios, err := Tracks(ctx, dir, resourceID)
+	if err != nil {
+		return err
+	}
+
+	if err := runCommand(ctx, nil, "ffmpeg", CMAFArgs(videos, audios, dst)...); err != nil {
+		return err
+	}
+
+	subs := Subtitles(dir, resourceID)
+	if len(videos) > 0 {
+		if err := WriteSubtitlePlaylists(dst, subs, videos[0].Duration); err != nil {
+			return err
+		}
+	}
+
+	if err := AddSubtitlesToMaster(filepath.Join(dst, "master.m3u8"), subs); err != nil {
+		return err
+	}
+
+	return AddSubtitlesToMPD(filepath.Join(dst, "manifest.mpd"), subs)
+}
+
+// CMAFArgs return the ffmpeg arguments to mux every track in a single fragmented mp4,
+// the hls_playlist option writes the master.m3u8 next to the manifest.mpd
+func CMAFArgs(videos, audios []Track, dst string) []string {
+	return append(dashInputArgs(videos, audios), "-c", "copy", "-f", "dash",
+		"-seg_duration", strconv.Itoa(settings.PackagingSetting.SegmentDuration),
+		"-single_file", "1", "-single_file_name", "$RepresentationID$.mp4",
+		"-hls_playlist", "1",
+		filepath.Join(dst, "manifest.mpd"))
+}
@@ -0,0 +1,97 @@
+package ffmpeg
+
+import (
+	"fmt"
+	"strings"
+)
+
+// avcProfiles map the profile reported by ffprobe to profile_idc and constraint flags
+var avcProfiles = map[string]string{
+	"Baseline":             "42E0",
+	"Constrained Baseline": "42E0",
+	"Main":                 "4D40",
+	"High":                 "6400",
+	"High 10":              "6E00",
+}
+
+// aacObjectTypes map the profile reported by ffprobe to the audio object type
+var aacObjectTypes = map[string]int{
+	"LC":       2,
+	"HE-AAC":   5,
+	"HE-AACv2": 29,
+}
+
+// hevcProfiles map the profile reported by ffprobe to general_profile_idc and compatibility flags
+var hevcProfiles = map[string]string{
+	"Main":    "1.6",
+	"Main 10": "2.4",
+}
+
+// encoderFamilies map the encoders of the ladder to the codec name reported by ffprobe
+var encoderFamilies = map[string]string{
+	"h264":       "h264",
+	"libx264":    "h264",
+	"hevc":       "hevc",
+	"libx265":    "hevc",
+	"vp9":        "vp9",
+	"libvpx-vp9": "vp9",
+	"av1":        "av1",
+	"libaom-av1": "av1",
+	"libsvtav1":  "av1",
+}
+
+// CodecFamily return the codec produced by the encoder
+func CodecFamily(encoder string) string {
+	if family, ok := encoderFamilies[encoder]; ok {
+		return family
+	}
+	return encoder
+}
+
+// CodecString return the RFC 6381 codec used on CODECS attributes of the manifests
+func CodecString(codecName, profile string, level int) string {
+	switch codecName {
+	case "h264":
+		p, ok := avcProfiles[profile]
+		if !ok {
+			p = avcProfiles["Main"]
+		}
+		return fmt.Sprintf("avc1.%s%02X", p, level)
+	case "hevc":
+		p, ok := hevcProfiles[profile]
+		if !ok {
+			p = hevcProfiles["Main"]
+		}
+		return fmt.Sprintf("hvc1.%s.L%d.B0", p, level)
+	case "vp9":
+		if level <= 0 {
+			level = 40
+		}
+		return fmt.Sprintf("vp09.%02d.%02d.08", vp9Profile(profile), level)
+	case "av1":
+		if level < 0 {
+			level = 8
+		}
+		return fmt.Sprintf("av01.0.%02dM.08", level)
+	case "aac":
+		aot, ok := aacObjectTypes[profile]
+		if !ok {
+			aot = aacObjectTypes["LC"]
+		}
+		return fmt.Sprintf("mp4a.40.%d", aot)
+	case "mp3":
+		return "mp4a.40.34"
+	case "ac3":
+		return "ac-3"
+	case "eac3":
+		return "ec-3"
+	}
+	return strings.ToLower(codecName)
+}
+
+// vp9Profile parse profiles like "Profile 0"
+func vp9Profile(profile string) int {
+	var p int
+	fmt.Sscanf(profile, "Profile %d", &p)
+	return p
+}
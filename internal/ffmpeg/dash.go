@@ -0,0 +1,90 @@
+package ffmpeg
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/Voodfy/voodfy-transcoder/internal/settings"
+)
+
+// DASHDir directory inside the output where the dash packaging is written
+const DASHDir = "dash"
+
+// PackageDASH segment the renditions and the audio of the resource and write the manifest.mpd
+func (c *Client) PackageDASH(ctx context.Context, dir, resourceID string) error {
+	dst := filepath.Join(dir, DASHDir)
+	os.MkdirAll(dst, 0777)
+
+	videos, audios, err := Tracks(ctx, dir, resourceID)
+	if err != nil {
+		return err
+	}
+
+	if err := runCommand(ctx, nil, "ffmpeg", DASHArgs(videos, audios, dst)...); err != nil {
+		return err
+	}
+
+	return AddSubtitlesToMPD(filepath.Join(dst, "manifest.mpd"), Subtitles(dir, resourceID))
+}
+
+// DASHArgs return the ffmpeg arguments to mux every track in a SegmentTemplate manifest
+func DASHArgs(videos, audios []Track, dst string) []string {
+	return append(dashInputArgs(videos, audios), "-c", "copy", "-f", "dash",
+		"-seg_duration", strconv.Itoa(settings.PackagingSetting.SegmentDuration),
+		"-use_template", "1", "-use_timeline", "0",
+		"-init_seg_name", "init_$RepresentationID$.m4s",
+		"-media_seg_name", "chunk_$RepresentationID$_$Number%05d$.m4s",
+		filepath.Join(dst, "manifest.mpd"))
+}
+
+// dashInputArgs return the inputs and maps of the tracks, the videos are grouped on one
+// adaptation set per codec and the audios on one per codec and source stream
+func dashInputArgs(videos, audios []Track) []string {
+	args := []string{"-hide_banner", "-y"}
+
+	for _, t := range append(videos, audios...) {
+		args = append(args, "-i", t.Source)
+	}
+
+	for idx := range videos {
+		args = append(args, "-map", fmt.Sprintf("%d:v:0", idx))
+	}
+
+	for idx := range audios {
+		args = append(args, "-map", fmt.Sprintf("%d:a:0", len(videos)+idx))
+	}
+
+	sets := adaptationSets(videos, 0, 0)
+	sets = append(sets, adaptationSets(audios, len(videos), len(sets))...)
+	args = append(args, "-adaptation_sets", strings.Join(sets, " "))
+
+	// ffmpeg 4.1 only writes opus on mp4 as experimental
+	if len(filterCodec(audios, "opus")) > 0 {
+		args = append(args, "-strict", "experimental")
+	}
+	return args
+}
+
+// adaptationSets return one adaptation set per codec and audio stream of the tracks, offset is
+// the index of the first track on the output and id the id of the first set
+func adaptationSets(tracks []Track, offset, id int) []string {
+	var keys []string
+	groups := map[string][]string{}
+	for idx, t := range tracks {
+		key := fmt.Sprintf("%s_%s_%d", t.Codec, t.Stream.Language, t.Stream.Index)
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], strconv.Itoa(offset+idx))
+	}
+
+	var sets []string
+	for idx, key := range keys {
+		sets = append(sets, fmt.Sprintf("id=%d,streams=%s", id+idx, strings.Join(groups[key], ",")))
+	}
+	return sets
+}
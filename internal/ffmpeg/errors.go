@@ -0,0 +1,135 @@
+package ffmpeg
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// Kind classification of the failure of a command
+type Kind string
+
+const (
+	// KindUnknown failure without a known cause
+	KindUnknown Kind = "unknown"
+	// KindInputCorrupt the source can't be demuxed or decoded
+	KindInputCorrupt Kind = "input_corrupt"
+	// KindEncoderFailure the encoder or the output stream can't be opened
+	KindEncoderFailure Kind = "encoder_failure"
+	// KindDiskFull the output can't be written
+	KindDiskFull Kind = "disk_full"
+	// KindKilled the command was killed by the timeout, a cancellation or a signal
+	KindKilled Kind = "killed"
+)
+
+// patterns messages written by ffmpeg on stderr used to classify the failure
+var patterns = []struct {
+	kind    Kind
+	message string
+}{
+	{KindDiskFull, "No space left on device"},
+	{KindDiskFull, "Disk quota exceeded"},
+	{KindEncoderFailure, "Unknown encoder"},
+	{KindEncoderFailure, "Error while opening encoder"},
+	{KindEncoderFailure, "Error initializing output stream"},
+	{KindEncoderFailure, "not divisible by 2"},
+	{KindEncoderFailure, "Incompatible pixel format"},
+	{KindInputCorrupt, "Invalid data found when processing input"},
+	{KindInputCorrupt, "moov atom not found"},
+	{KindInputCorrupt, "could not find codec parameters"},
+	{KindInputCorrupt, "Error while decoding stream"},
+	{KindInputCorrupt, "No such file or directory"},
+}
+
+// Error struct used to describe the failure of a command
+type Error struct {
+	Command  []string
+	ExitCode int
+	Stderr   []string
+	Kind     Kind
+	Err      error
+}
+
+// Error return the kind, the cause and the last line written on stderr
+func (e *Error) Error() string {
+	msg := fmt.Sprintf("%s: %s: %v", e.Command[0], e.Kind, e.Err)
+	if len(e.Stderr) > 0 {
+		msg = fmt.Sprintf("%s: %s", msg, e.Stderr[len(e.Stderr)-1])
+	}
+	return msg
+}
+
+// Unwrap return the cause, ErrTimeout when the time limit killed the command
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Extra return the context sent to sentry
+func (e *Error) Extra() map[string]interface{} {
+	return map[string]interface{}{
+		"command":   strings.Join(e.Command, " "),
+		"exit_code": e.ExitCode,
+		"stderr":    strings.Join(e.Stderr, "\n"),
+		"kind":      string(e.Kind),
+	}
+}
+
+// classify return the kind of the first known message found on stderr
+func classify(stderr []string) Kind {
+	for _, line := range stderr {
+		for _, p := range patterns {
+			if strings.Contains(line, p.message) {
+				return p.kind
+			}
+		}
+	}
+	return KindUnknown
+}
+
+// tail io.Writer that keep the last lines written by the command
+type tail struct {
+	size    int
+	lines   []string
+	partial []byte
+}
+
+// newTail return a tail that keep size lines
+func newTail(size int) *tail {
+	return &tail{size: size}
+}
+
+// Write split the output by lines, ffmpeg uses \r to rewrite the stats line
+func (t *tail) Write(b []byte) (int, error) {
+	t.partial = append(t.partial, b...)
+	for {
+		idx := bytes.IndexAny(t.partial, "\r\n")
+		if idx < 0 {
+			break
+		}
+		t.push(string(t.partial[:idx]))
+		t.partial = t.partial[idx+1:]
+	}
+	return len(b), nil
+}
+
+// Lines return the lines kept including the last one without line break
+func (t *tail) Lines() []string {
+	lines := append([]string{}, t.lines...)
+	if line := strings.TrimSpace(string(t.partial)); line != "" {
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// push add the line removing the oldest when the size is reached
+func (t *tail) push(line string) {
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return
+	}
+
+	t.lines = append(t.lines, line)
+	if len(t.lines) > t.size {
+		t.lines = t.lines[len(t.lines)-t.size:]
+	}
+}
@@ -0,0 +1,93 @@
+package ffmpeg
+
+import (
+	"context"
+	"io"
+	"os"
+	"os/exec"
+	"syscall"
+
+	"github.com/Voodfy/voodfy-transcoder/internal/settings"
+)
+
+// runCommand start the command and wait it finish, the process group is killed when
+// the context is done, stdout is written to os.Stdout when it is nil, the failures
+// are returned as *Error with the tail of stderr
+func runCommand(ctx context.Context, stdout io.Writer, name string, args ...string) error {
+	return execute(ctx, stdout, nil, name, args...)
+}
+
+// execute run the command like runCommand, the whole stderr is copied to the writer when it isn't nil
+func execute(ctx context.Context, stdout, output io.Writer, name string, args ...string) error {
+	cmd := command(name, args...)
+	stderr := newTail(settings.FFmpegSetting.StderrLines)
+
+	if stdout == nil {
+		stdout = os.Stdout
+	}
+	cmd.Stdout = stdout
+	cmd.Stderr = io.MultiWriter(os.Stderr, stderr)
+	if output != nil {
+		cmd.Stderr = io.MultiWriter(os.Stderr, stderr, output)
+	}
+
+	if err := cmd.Start(); err != nil {
+		return &Error{Command: cmd.Args, ExitCode: -1, Kind: KindUnknown, Err: err}
+	}
+
+	stop := watch(ctx, cmd)
+	err := cmd.Wait()
+	stop()
+
+	if err == nil {
+		return nil
+	}
+
+	e := &Error{Command: cmd.Args, ExitCode: -1, Stderr: stderr.Lines(), Kind: classify(stderr.Lines()), Err: err}
+
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		e.ExitCode = exitErr.ExitCode()
+		if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+			e.Kind = KindKilled
+		}
+	}
+
+	switch ctx.Err() {
+	case context.DeadlineExceeded:
+		e.Kind, e.Err = KindKilled, ErrTimeout
+	case context.Canceled:
+		e.Kind, e.Err = KindKilled, ctx.Err()
+	}
+
+	return e
+}
+
+// runWithProgress run the command parsing the blocks written on stdout by -progress
+func runWithProgress(ctx context.Context, report Reporter, name string, args ...string) error {
+	pr, pw := io.Pipe()
+	done := make(chan struct{})
+
+	go func() {
+		ParseProgress(pr, report)
+		close(done)
+	}()
+
+	err := runCommand(ctx, pw, name, append(append([]string{}, progressArgs...), args...)...)
+	pw.Close()
+	<-done
+
+	return err
+}
+
+// watch kill the process group of the command when the context is done before the returned func is called
+func watch(ctx context.Context, cmd *exec.Cmd) func() {
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			kill(cmd)
+		case <-done:
+		}
+	}()
+	return func() { close(done) }
+}
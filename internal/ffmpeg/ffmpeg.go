@@ -2,40 +2,49 @@ package ffmpeg
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
-	"log"
-	"os"
-	"os/exec"
 	"strconv"
+	"time"
 
 	"github.com/Voodfy/voodfy-transcoder/internal/models"
-	"github.com/Voodfy/voodfy-transcoder/internal/utils"
+	"github.com/Voodfy/voodfy-transcoder/internal/settings"
 )
 
 var (
 	// ExecFunc is command func.
 	ExecFunc = ExecCmd
+
+	// ErrTimeout returned when the command is killed by the time limit
+	ErrTimeout = errors.New("ffmpeg: timeout")
 )
 
 // Commands interface from ffmpeg
 type Commands interface {
-	RemoveAudioFromMP4(string, string) bool
-	GenerateImageFromFrameVideo(string, string, string) bool
-	GenerateWebpFromFrameVideo(string, string, string) bool
-	Transcode90p(string, string) bool
-	Transcode144p(string, string) bool
-	Transcode240p(string, string) bool
-	Transcode360p(string, string) bool
-	Transcode480p(string, string) bool
-	Transcode720p(string, string) bool
-	Transcode1080p(string, string) bool
-	ConvertToMp4(string, string) bool
-	ThumbsPreviewGenerator(string, string, string) bool
-	VTTGenerator(string, string, string) bool
-	ExtractAudioFromMp4(string, string) bool
-	CheckIntegrityFromMp4s(string, string) bool
+	RemoveAudioFromMP4(context.Context, string, string) error
+	GenerateImageFromFrameVideo(context.Context, string, string, float64) error
+	SelectPoster(context.Context, string, float64) (models.Poster, error)
+	GeneratePosterVariants(context.Context, string, float64) error
+	GenerateWebpFromFrameVideo(context.Context, string, string, time.Duration) error
+	Transcode(context.Context, string, string, settings.RenditionProfile, Reporter) error
+	TranscodeChunk(context.Context, string, string, settings.RenditionProfile, models.Chunk, Reporter) error
+	Concat(context.Context, []string, string) error
+	TranscodeAudio(context.Context, string, string, settings.AudioProfile, models.AudioStream, Reporter) error
+	AnalyzeLoudness(context.Context, string, int) (models.Loudness, error)
+	ConvertToMp4(context.Context, string, string) error
+	ThumbsPreviewGenerator(context.Context, string, string, time.Duration) error
+	BIF(context.Context, string, string, string) error
+	VTTGenerator(context.Context, string, string, string) error
+	ExtractSubtitle(context.Context, string, string, string, int) error
+	ExtractAudioFromMp4(context.Context, string, string) error
+	ValidateRendition(context.Context, string, string, settings.RenditionProfile) error
+	Keyframes(context.Context, string) ([]float64, error)
+	PackageHLS(context.Context, string, string) error
+	PackageDASH(context.Context, string, string) error
+	PackageCMAF(context.Context, string, string) error
+	SampleBitrate(context.Context, string, settings.RenditionProfile, float64, float64) (int, error)
 }
 
 // Client instance of ffmpeg
@@ -47,417 +56,264 @@ func NewClient() (c Client) {
 }
 
 // Run execute the ffmpeg job
-func Run(cmd Commands, fnc string, args ...string) bool {
+func Run(ctx context.Context, cmd Commands, fnc string, args ...string) error {
 	switch fnc {
 	case "FFprobe":
-		r, _ := Execute(args[0])
+		r, err := Execute(ctx, args[0])
+		if err != nil {
+			return err
+		}
 		r.ID = args[1]
 		r.Save()
+		return nil
 	case "RemoveAudioFromMp4":
-		return cmd.RemoveAudioFromMP4(args[0], args[1])
+		return cmd.RemoveAudioFromMP4(ctx, args[0], args[1])
 	case "ThumbsPreviewGenerator":
-		r, _ := Execute(args[0])
-		return cmd.ThumbsPreviewGenerator(args[0], args[1], r.Format.Duration)
+		info, err := Probe(ctx, args[0])
+		if err != nil {
+			return err
+		}
+		return cmd.ThumbsPreviewGenerator(ctx, args[0], args[1], info.Duration)
+	case "GenerateWebpFromFrameVideo":
+		info, err := Probe(ctx, args[0])
+		if err != nil {
+			return err
+		}
+		return cmd.GenerateWebpFromFrameVideo(ctx, args[0], args[1], info.Duration)
+	case "BIF":
+		return cmd.BIF(ctx, args[0], args[1], args[2])
 	case "GenerateImageFromFrameVideo":
-		r, _ := Execute(args[0])
-		return cmd.GenerateImageFromFrameVideo(args[0], args[1], r.Format.Duration)
+		return poster(ctx, cmd, args...)
 	case "ExtractAudioFromMp4":
-		return cmd.ExtractAudioFromMp4(args[0], args[1])
-	case "90p":
-		cmd.Transcode90p(args[0], args[1])
-	case "144p":
-		cmd.Transcode144p(args[0], args[1])
-	case "240p":
-		cmd.Transcode240p(args[0], args[1])
-	case "360p":
-		cmd.Transcode360p(args[0], args[1])
-	case "480p":
-		cmd.Transcode480p(args[0], args[1])
-	case "720p":
-		cmd.Transcode720p(args[0], args[1])
-	case "1080p":
-		cmd.Transcode1080p(args[0], args[1])
+		return cmd.ExtractAudioFromMp4(ctx, args[0], args[1])
 	case "convertToMp4":
-		cmd.ConvertToMp4(args[0], args[1])
-	}
-	return false
-}
-
-// ExecCmd exec ffprobe command and return result of json.
-func ExecCmd(fileName string) ([]byte, error) {
-	return exec.Command("ffprobe",
-		"-v", "quiet", "-print_format", "json", "-show_format", "-show_streams", fileName).Output()
+		return cmd.ConvertToMp4(ctx, args[0], args[1])
+	case "Subtitles":
+		return subtitles(ctx, cmd, args...)
+	case "AudioRendition":
+		return audioRendition(ctx, cmd, args...)
+	case "AnalyzeLoudness":
+		job := models.Job{ID: args[1]}
+		job.Get()
+
+		for idx, s := range job.Streams {
+			l, err := cmd.AnalyzeLoudness(ctx, args[0], s.Index)
+			if err != nil {
+				return err
+			}
+			job.Streams[idx].Loudness = &l
+		}
+
+		job.Save()
+		return nil
+	case "AnalyzeComplexity":
+		info, err := Probe(ctx, args[0])
+		if err != nil {
+			return err
+		}
+
+		job := models.Job{ID: args[1]}
+		job.Get()
+		job.Ladder = PerTitle(ctx, cmd, args[0], info.Duration.Seconds(), job.Ladder)
+		job.Save()
+		return nil
+	case "ValidateRendition":
+		return validate(ctx, cmd, args...)
+	case "ChunkRendition":
+		return chunkRendition(ctx, cmd, args...)
+	case "ConcatRendition":
+		return concatRendition(ctx, cmd, args...)
+	case "PackageHLS":
+		return cmd.PackageHLS(ctx, args[0], args[1])
+	case "PackageDASH":
+		return cmd.PackageDASH(ctx, args[0], args[1])
+	case "PackageCMAF":
+		return cmd.PackageCMAF(ctx, args[0], args[1])
+	}
+
+	p, ok := profile(fnc, args...)
+	if !ok {
+		return fmt.Errorf("ffmpeg: unknown job %s", fnc)
+	}
+	return cmd.Transcode(ctx, args[0], args[1], p, reporter(ctx, p.Name, args...))
 }
 
-// Execute exec command and bind result to struct.
-func Execute(fileName string) (r models.Specification, err error) {
-	out, err := ExecFunc(fileName)
-
-	if err != nil {
-		return r, err
+// reporter return the reporter that store the progress of the task when the job id is given
+func reporter(ctx context.Context, task string, args ...string) Reporter {
+	if len(args) <= 3 {
+		return nil
 	}
 
-	if err := json.Unmarshal(out, &r); err != nil {
-		return r, err
-	}
-
-	return r, nil
+	// without the duration the progress is stored without the percentage
+	info, _ := Probe(ctx, args[0])
+	return StoreProgress(args[3], task, info.Duration)
 }
 
-// RemoveAudioFromMP4 generate a mp4 without audion
-func (c *Client) RemoveAudioFromMP4(filename, dstFile string) bool {
-	var stdBuffer bytes.Buffer
-
-	cmd := exec.Command("ffmpeg", "-hide_banner", "-y", "-i", filename, "-c", "copy", "-an", dstFile)
-	mw := io.MultiWriter(os.Stdout, &stdBuffer)
-	cmd.Stdout = mw
-	cmd.Stderr = mw
-
-	err := cmd.Start()
-	if err != nil {
-		utils.SendError(fmt.Sprintf("%s-RemoveAudioFromMP4-cmd.Start() failed with '%s'\n", filename, err), err)
-		return false
-	}
-
-	err = cmd.Wait()
-	if err != nil {
-		utils.SendError(fmt.Sprintf("%s-RemoveAudioFromMP4-cmd.Start() failed with '%s'\n", filename, err), err)
-		return false
-	}
-	return true
+// profile return the rendition registered on the ladder of the worker with the size and the
+// bitrates planned to the job, the encoder options of the app.ini of the worker are kept
+func profile(fnc string, args ...string) (settings.RenditionProfile, bool) {
+	p, ok := settings.LadderSetting.Profile(fnc)
+	if len(args) > 3 {
+		job := models.Job{ID: args[3]}
+		job.Get()
+		if planned, found := job.Profile(fnc); found {
+			if !ok {
+				return planned, true
+			}
+			p.Width, p.Height = planned.Width, planned.Height
+			p.Bitrate, p.Maxrate, p.Bufsize = planned.Bitrate, planned.Maxrate, planned.Bufsize
+			return p, true
+		}
+	}
+	return p, ok
 }
 
-// GenerateImageFromFrameVideo generate a jpg from mp4
-func (c *Client) GenerateImageFromFrameVideo(filename, dstFile, duration string) bool {
-	var stdBuffer bytes.Buffer
-	var position string
-	position = "00:00:01"
-
-	d, err := strconv.ParseFloat(duration, 64)
-
-	if d >= 5.0 {
-		position = "00:00:05"
-	}
-
-	cmd := exec.Command("ffmpeg", "-hide_banner", "-y", "-ss", position, "-i", filename, "-vframes", "1", "-q:v", "1", fmt.Sprintf("%sposter.jpg", dstFile))
-	mw := io.MultiWriter(os.Stdout, &stdBuffer)
-	cmd.Stdout = mw
-	cmd.Stderr = mw
-
-	err = cmd.Start()
-	if err != nil {
-		utils.SendError(fmt.Sprintf("%s-GenerateImageFromFrameVideo-cmd.Start() failed with '%s'\n", filename, err), err)
-		return false
-	}
-
-	err = cmd.Wait()
-	if err != nil {
-		utils.SendError(fmt.Sprintf("%s-GenerateImageFromFrameVideo-cmd.Start() failed with '%s'\n", filename, err), err)
-		return false
+// Timeout return the time limit of the job, a multiple of the source duration set by
+// the rendition profile or by the ffmpeg setting and doubled to the two-pass renditions,
+// the default is used when the input isn't a media like the directories given to the packaging
+func Timeout(ctx context.Context, fnc string, args ...string) time.Duration {
+	cfg := settings.FFmpegSetting
+	factor := cfg.TimeoutFactor
+	p, ok := profile(fnc, args...)
+	if ok && p.TimeoutFactor > 0 {
+		factor = p.TimeoutFactor
 	}
-	return true
-}
-
-// GenerateWebpFromFrameVideo generate a jpg from mp4
-func (c *Client) GenerateWebpFromFrameVideo(filename, dstFile, duration string) bool {
-	var stdBuffer bytes.Buffer
-	var position string
-	position = "00:00:01"
-	d, err := strconv.ParseFloat(duration, 64)
-
-	if d >= 5.0 {
-		position = "00:00:05"
+	// the analysis pass reads the whole source again
+	if ok && p.Mode() == settings.RateControlTwoPass {
+		factor *= 2
 	}
 
-	cmd := exec.Command("ffmpeg", "-hide_banner", "-i", filename, "-lossless", "0", "-ss", "00:00:00", "-t", position, "-s", "384x182", fmt.Sprintf("%sposter.webp", dstFile))
-	mw := io.MultiWriter(os.Stdout, &stdBuffer)
-	cmd.Stdout = mw
-	cmd.Stderr = mw
-
-	err = cmd.Start()
-	if err != nil {
-		utils.SendError(fmt.Sprintf("%s-ConvertToMp4-cmd.Start() failed with '%s'\n", filename, err), err)
-		return false
+	info, err := Probe(ctx, args[0])
+	if err != nil || info.Duration <= 0 {
+		return cfg.DefaultTimeout * time.Second
 	}
 
-	err = cmd.Wait()
-	if err != nil {
-		utils.SendError(fmt.Sprintf("%s-ConvertToMp4-cmd.Start() failed with '%s'\n", filename, err), err)
-		return false
+	timeout := time.Duration(float64(info.Duration) * factor)
+	if timeout < cfg.MinTimeout*time.Second {
+		return cfg.MinTimeout * time.Second
 	}
-	log.Println("finish 240p ~> ", filename)
-
-	return true
+	return timeout
 }
 
-// ConvertToMp4 convert mkv to mp4
-func (c *Client) ConvertToMp4(filename, dstFile string) bool {
-	var stdBuffer bytes.Buffer
-
-	cmd := exec.Command("ffmpeg", "-hide_banner", "-y", "-i", filename, "-movflags", "faststart", "-c", "copy", dstFile)
-
-	mw := io.MultiWriter(os.Stdout, &stdBuffer)
-	cmd.Stdout = mw
-	cmd.Stderr = mw
-	err := cmd.Start()
-
-	if err != nil {
-		utils.SendError(fmt.Sprintf("%s-ConvertToMp4-cmd.Start() failed with '%s'\n", filename, err), err)
-		return false
-	}
+// ExecCmd exec ffprobe command and return result of json.
+func ExecCmd(ctx context.Context, fileName string) ([]byte, error) {
+	var stdout bytes.Buffer
 
-	err = cmd.Wait()
-	if err != nil {
-		utils.SendError(fmt.Sprintf("%s-ConvertToMp4-cmd.Start() failed with '%s'\n", filename, err), err)
-		return false
-	}
+	err := runCommand(ctx, &stdout, "ffprobe",
+		"-v", "error", "-print_format", "json", "-show_format", "-show_streams", fileName)
 
-	return true
+	return stdout.Bytes(), err
 }
 
-// Transcode90p low definition
-func (c *Client) Transcode90p(filename, dstFile string) bool {
-	var stdBuffer bytes.Buffer
-
-	cmd := exec.Command("ffmpeg", "-hide_banner", "-y", "-i", filename, "-movflags", "faststart", "-vf", "scale='-2:90'", "-c:v", "h264", "-profile:v", "main", "-crf", "20", "-sc_threshold", "0", "-g", "48", "-keyint_min", "48", "-b:v", "100k", "-an", dstFile)
-
-	mw := io.MultiWriter(os.Stdout, &stdBuffer)
-	cmd.Stdout = mw
-	cmd.Stderr = mw
-	err := cmd.Start()
+// Execute exec command and bind result to struct.
+func Execute(ctx context.Context, fileName string) (r models.Specification, err error) {
+	out, err := ExecFunc(ctx, fileName)
 
 	if err != nil {
-		utils.SendError(fmt.Sprintf("%s-Transcode90p-cmd.Start() failed with '%s'\n", filename, err), err)
-		return false
+		return r, err
 	}
 
-	err = cmd.Wait()
-	if err != nil {
-		utils.SendError(fmt.Sprintf("%s-Transcode90p-cmd.Start() failed with '%s'\n", filename, err), err)
-		return false
+	if err := json.Unmarshal(out, &r); err != nil {
+		return r, err
 	}
 
-	return true
+	return r, nil
 }
 
-// Transcode144p low definition
-func (c *Client) Transcode144p(filename, dstFile string) bool {
-	var stdBuffer bytes.Buffer
-
-	cmd := exec.Command("ffmpeg", "-hide_banner", "-y", "-i", filename, "-movflags", "faststart", "-vf", "scale='-2:90'", "-c:v", "h264", "-profile:v", "main", "-crf", "20", "-sc_threshold", "0", "-g", "48", "-keyint_min", "48", "-b:v", "100k", "-an", dstFile)
-
-	mw := io.MultiWriter(os.Stdout, &stdBuffer)
-	cmd.Stdout = mw
-	cmd.Stderr = mw
-	err := cmd.Start()
-
+// Probe exec ffprobe and return the media info normalized from the specification
+func Probe(ctx context.Context, fileName string) (models.MediaInfo, error) {
+	r, err := Execute(ctx, fileName)
 	if err != nil {
-		utils.SendError(fmt.Sprintf("%s-Transcode144p-cmd.Start() failed with '%s'\n", filename, err), err)
-		return false
+		return models.MediaInfo{}, err
 	}
 
-	err = cmd.Wait()
-	if err != nil {
-		utils.SendError(fmt.Sprintf("%s-Transcode144p-cmd.Start() failed with '%s'\n", filename, err), err)
-		return false
-	}
-
-	return true
+	return r.MediaInfo()
 }
 
-// Transcode240p 240p
-func (c *Client) Transcode240p(filename, dstFile string) bool {
-	var stdBuffer bytes.Buffer
-	cmd := exec.Command("ffmpeg", "-hide_banner", "-y", "-i", filename, "-movflags", "faststart", "-vf", "scale='-2:240'", "-c:v", "h264", "-profile:v", "main", "-crf", "20", "-sc_threshold", "0", "-g", "48", "-keyint_min", "48", "-b:v", "120k", "-an", dstFile)
-	mw := io.MultiWriter(os.Stdout, &stdBuffer)
-	cmd.Stdout = mw
-	cmd.Stderr = mw
-
-	err := cmd.Start()
-	if err != nil {
-		utils.SendError(fmt.Sprintf("%s-Transcode240p-cmd.Start() failed with '%s'\n", filename, err), err)
-		return false
-	}
-
-	err = cmd.Wait()
-	if err != nil {
-		utils.SendError(fmt.Sprintf("%s-Transcode240p-cmd.Start() failed with '%s'\n", filename, err), err)
-		return false
-	}
-	return true
+// RemoveAudioFromMP4 generate a mp4 without audion
+func (c *Client) RemoveAudioFromMP4(ctx context.Context, filename, dstFile string) error {
+	return runCommand(ctx, nil, "ffmpeg", "-hide_banner", "-y", "-i", filename, "-c", "copy", "-an", dstFile)
 }
 
-// Transcode360p 360p
-func (c *Client) Transcode360p(filename, dstFile string) bool {
-	var stdBuffer bytes.Buffer
-	cmd := exec.Command("ffmpeg", "-hide_banner", "-y", "-i", filename, "-movflags", "faststart", "-vf", "scale='-2:360'", "-c:v", "h264", "-profile:v", "main", "-crf", "20", "-sc_threshold", "0", "-g", "48", "-keyint_min", "48", "-b:v", "284k", "-maxrate", "284k", "-bufsize", "568k", "-an", dstFile)
-	mw := io.MultiWriter(os.Stdout, &stdBuffer)
-	cmd.Stdout = mw
-	cmd.Stderr = mw
-	err := cmd.Start()
-	if err != nil {
-		utils.SendError(fmt.Sprintf("%s-Transcode360p-cmd.Start() failed with '%s'\n", filename, err), err)
-		return false
-	}
-
-	err = cmd.Wait()
-	if err != nil {
-		utils.SendError(fmt.Sprintf("%s-Transcode360p-cmd.Start() failed with '%s'\n", filename, err), err)
-		return false
-	}
-	return true
+// GenerateImageFromFrameVideo generate a jpg from mp4 at the position in seconds
+func (c *Client) GenerateImageFromFrameVideo(ctx context.Context, filename, dstFile string, position float64) error {
+	return runCommand(ctx, nil, "ffmpeg", "-hide_banner", "-y", "-ss", fmt.Sprintf("%.3f", position), "-i", filename, "-vframes", "1", "-q:v", "1", fmt.Sprintf("%sposter.jpg", dstFile))
 }
 
-// Transcode480p 480p
-func (c *Client) Transcode480p(filename, dstFile string) bool {
-	var stdBuffer bytes.Buffer
+// ConvertToMp4 convert mkv to mp4
+func (c *Client) ConvertToMp4(ctx context.Context, filename, dstFile string) error {
+	return runCommand(ctx, nil, "ffmpeg", "-hide_banner", "-y", "-i", filename, "-movflags", "faststart", "-c", "copy", dstFile)
+}
 
-	cmd := exec.Command("ffmpeg", "-hide_banner", "-y", "-i", filename, "-movflags", "faststart", "-vf", "scale='-2:480'", "-c:v", "h264", "-profile:v", "main", "-crf", "20", "-sc_threshold", "0", "-g", "48", "-keyint_min", "48", "-b:v", "341k", "-maxrate", "341k", "-bufsize", "682k", "-an", dstFile)
-	mw := io.MultiWriter(os.Stdout, &stdBuffer)
-	cmd.Stdout = mw
-	cmd.Stderr = mw
+// Transcode generate the rendition described by the profile, the progress is sent to the reporter
+func (c *Client) Transcode(ctx context.Context, filename, dstFile string, p settings.RenditionProfile, report Reporter) error {
+	return encode(ctx, report, p, RenditionArgs(filename, dstFile, p))
+}
 
-	err := cmd.Start()
-	if err != nil {
-		utils.SendError(fmt.Sprintf("%s-Transcode480p-cmd.Start() failed with '%s'\n", filename, err), err)
-		return false
-	}
+// RenditionArgs return the ffmpeg arguments of the profile, the empty fields are omitted
+func RenditionArgs(filename, dstFile string, p settings.RenditionProfile) []string {
+	args := []string{"-hide_banner", "-y", "-i", filename}
 
-	err = cmd.Wait()
-	if err != nil {
-		utils.SendError(fmt.Sprintf("%s-Transcode480p-cmd.Start() failed with '%s'\n", filename, err), err)
-		return false
+	if p.Extension() == "mp4" {
+		args = append(args, "-movflags", "faststart")
 	}
 
-	return true
-}
+	args = append(args, "-vf", scaleFilter(p), "-c:v", p.Codec)
 
-// Transcode720p 720p
-func (c *Client) Transcode720p(filename, dstFile string) bool {
-	var stdBuffer bytes.Buffer
-	cmd := exec.Command("ffmpeg", "-hide_banner", "-y", "-i", filename, "-movflags", "faststart", "-vf", "scale='-2:720'", "-c:v", "h264", "-profile:v", "main", "-crf", "20", "-sc_threshold", "0", "-g", "48", "-keyint_min", "48", "-b:v", "765k", "-maxrate", "765k", "-bufsize", "1530k", "-an", dstFile)
-	mw := io.MultiWriter(os.Stdout, &stdBuffer)
-	cmd.Stdout = mw
-	cmd.Stderr = mw
-	err := cmd.Start()
-	if err != nil {
-		utils.SendError(fmt.Sprintf("%s-Transcode720p-cmd.Start() failed with '%s'\n", filename, err), err)
-		return false
+	if p.Profile != "" {
+		args = append(args, "-profile:v", p.Profile)
 	}
 
-	err = cmd.Wait()
-	if err != nil {
-		utils.SendError(fmt.Sprintf("%s-Transcode720p-cmd.Start() failed with '%s'\n", filename, err), err)
-		return false
+	if p.GOP > 0 {
+		args = append(args, keyframeArgs(p)...)
 	}
-	return true
-}
-
-// Transcode1080p 1080p
-func (c *Client) Transcode1080p(filename, dstFile string) bool {
-	var stdBuffer bytes.Buffer
-	cmd := exec.Command("ffmpeg", "-hide_banner", "-y", "-i", filename, "-movflags", "faststart", "-vf", "scale='-2:1080'", "-c:v", "h264", "-profile:v", "main", "-crf", "20", "-sc_threshold", "0", "-g", "48", "-keyint_min", "48", "-b:v", "1579k", "-maxrate", "1579k", "-bufsize", "3158k", "-an", dstFile)
 
-	mw := io.MultiWriter(os.Stdout, &stdBuffer)
-	cmd.Stdout = mw
-	cmd.Stderr = mw
+	args = append(args, RateControlArgs(p)...)
 
-	err := cmd.Start()
+	return append(append(args, encoderArgs(p)...), "-an", dstFile)
+}
 
-	if err != nil {
-		utils.SendError(fmt.Sprintf("%s-Transcode1080p-cmd.Start() failed with '%s'\n", filename, err), err)
-		return false
-	}
+// keyframeArgs return the fixed gop of the profile, the scene cut is disabled to keep
+// the keyframes aligned between the renditions
+func keyframeArgs(p settings.RenditionProfile) []string {
+	gop := strconv.Itoa(p.GOP)
 
-	err = cmd.Wait()
-	if err != nil {
-		utils.SendError(fmt.Sprintf("%s-Transcode1080p-cmd.Start() failed with '%s'\n", filename, err), err)
-		return false
+	switch p.Codec {
+	case "libx265":
+		return []string{"-g", gop, "-keyint_min", gop, "-x265-params", "scenecut=0:open-gop=0"}
+	case "libvpx-vp9", "libaom-av1", "libsvtav1":
+		return []string{"-g", gop, "-keyint_min", gop}
 	}
-	return true
+	return []string{"-sc_threshold", "0", "-g", gop, "-keyint_min", gop}
 }
 
-// ThumbsPreviewGenerator ...
-func (c *Client) ThumbsPreviewGenerator(filename, dstFile, duration string) bool {
-	var err error
-	d, err := strconv.ParseFloat(duration, 64)
-	if err != nil {
-		utils.SendError(fmt.Sprintf("%s-RemoveAudioFromMP4-cmd.Start() failed with '%s'\n", filename, err), err)
-		return false
-	}
-	columnsTotal := int(d) / 5 / 2
-	cmd := exec.Command("thumbsgenerator", filename, "5", "126", "73", fmt.Sprintf("%d", columnsTotal), fmt.Sprintf("%s/thumbspreview.png", dstFile))
-
-	var stdBuffer bytes.Buffer
-	mw := io.MultiWriter(os.Stdout, &stdBuffer)
-
-	cmd.Stdout = mw
-	cmd.Stderr = mw
-	err = cmd.Start()
-
-	if err != nil {
-		utils.SendError(fmt.Sprintf("%s-ThumbsPreviewGenerator-cmd.Start() failed with '%s'\n", filename, err), err)
-		return false
-	}
+// encoderArgs return the options required by each encoder
+func encoderArgs(p settings.RenditionProfile) []string {
+	switch p.Codec {
+	case "libx265":
+		return []string{"-tag:v", "hvc1"}
+	case "libvpx-vp9":
+		return []string{"-row-mt", "1", "-deadline", "good", "-cpu-used", "2"}
+	case "libaom-av1":
+		return []string{"-row-mt", "1", "-cpu-used", "6"}
+	}
+	return nil
+}
 
-	err = cmd.Wait()
-	if err != nil {
-		utils.SendError(fmt.Sprintf("%s-ThumbsPreviewGenerator-cmd.Start() failed with '%s'\n", filename, err), err)
-		return false
+// scaleFilter return the scale of the profile, the width follows the aspect ratio when it isn't planned
+func scaleFilter(p settings.RenditionProfile) string {
+	if p.Width > 0 {
+		return fmt.Sprintf("scale=%d:%d,setsar=1", p.Width, p.Height)
 	}
-	return true
+	return fmt.Sprintf("scale='-2:%d'", p.Height)
 }
 
 // VTTGenerator ...
-func (c *Client) VTTGenerator(filename, dstFile, language string) bool {
-	cmd := exec.Command("ffmpeg", "-hide_banner", "-y", "-i", filename, "-f", "webvtt", fmt.Sprintf("%s_%s.vtt", dstFile, language))
-
-	var stdBuffer bytes.Buffer
-	mw := io.MultiWriter(os.Stdout, &stdBuffer)
-
-	cmd.Stdout = mw
-	cmd.Stderr = mw
-	err := cmd.Start()
-
-	if err != nil {
-		utils.SendError(fmt.Sprintf("%s-VTTGenerator.Start() failed with '%s'\n", filename, err), err)
-		return false
-	}
-
-	err = cmd.Wait()
-	if err != nil {
-		utils.SendError(fmt.Sprintf("%s-VTTGenerator-cmd.Start() failed with '%s'\n", filename, err), err)
-		return false
-	}
-	return true
+func (c *Client) VTTGenerator(ctx context.Context, filename, dstFile, language string) error {
+	return runCommand(ctx, nil, "ffmpeg", "-hide_banner", "-y", "-i", filename, "-f", "webvtt", fmt.Sprintf("%s_%s.vtt", dstFile, language))
 }
 
 // ExtractAudioFromMp4 generate a m4a extracting the audio from mp4
-func (c *Client) ExtractAudioFromMp4(filename, dstFile string) bool {
-	cmd := exec.Command("ffmpeg", "-hide_banner", "-y", "-i", filename, "-vn", "-acodec", "copy", dstFile)
-	err := cmd.Start()
-	if err != nil {
-		utils.SendError(fmt.Sprintf("%s-ExtractAudioFromMp4-cmd.Start() failed with '%s'\n", filename, err), err)
-		return false
-	}
-
-	err = cmd.Wait()
-	if err != nil {
-		utils.SendError(fmt.Sprintf("%s-ExtractAudioFromMp4-cmd.Start() failed with '%s'\n", filename, err), err)
-		return false
-	}
-
-	return true
-}
-
-// CheckIntegrityFromMp4s return a boolean about the duration of the video
-func (c *Client) CheckIntegrityFromMp4s(source, output string) bool {
-	s, _ := Execute(source)
-	o, _ := Execute(output)
-
-	if o.Format.Duration == s.Format.Duration {
-		return true
-	}
-
-	return false
+func (c *Client) ExtractAudioFromMp4(ctx context.Context, filename, dstFile string) error {
+	return runCommand(ctx, nil, "ffmpeg", "-hide_banner", "-y", "-i", filename, "-vn", "-acodec", "copy", dstFile)
 }
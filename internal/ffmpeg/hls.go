@@ -0,0 +1,297 @@
+package ffmpeg
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Voodfy/voodfy-transcoder/internal/models"
+	"github.com/Voodfy/voodfy-transcoder/internal/settings"
+)
+
+// HLSDir directory inside the output where the hls packaging is written
+const HLSDir = "hls"
+
+// Track struct used to describe a rendition or audio track of the manifests, Bandwidth is the
+// peak bitrate over the segments and AverageBandwidth the bitrate of the whole track
+type Track struct {
+	Name             string
+	Source           string
+	Bandwidth        int
+	AverageBandwidth int
+	Width            int
+	Height           int
+	Codec            string
+	Codecs           string
+	Duration         float64
+	Group            string
+	Stream           models.AudioStream
+}
+
+// Tracks return the video renditions and audio tracks produced to the resource
+func Tracks(ctx context.Context, dir, resourceID string) (videos, audios []Track, err error) {
+	mp4s, err := filepath.Glob(filepath.Join(dir, fmt.Sprintf("%s_v*.mp4", resourceID)))
+	if err != nil {
+		return videos, audios, err
+	}
+
+	webms, err := filepath.Glob(filepath.Join(dir, fmt.Sprintf("%s_v*.webm", resourceID)))
+	if err != nil {
+		return videos, audios, err
+	}
+
+	m4as, err := filepath.Glob(filepath.Join(dir, fmt.Sprintf("%s_a*.m4a", resourceID)))
+	if err != nil {
+		return videos, audios, err
+	}
+
+	for _, f := range append(mp4s, webms...) {
+		t, err := probeTrack(ctx, f, "video")
+		if err != nil {
+			return videos, audios, err
+		}
+		videos = append(videos, t)
+	}
+
+	job := models.Job{ID: resourceID}
+	if len(m4as) > 0 {
+		job.Get()
+	}
+
+	for _, f := range m4as {
+		t, err := probeTrack(ctx, f, "audio")
+		if err != nil {
+			return videos, audios, err
+		}
+		audios = append(audios, audioTrack(t, resourceID, job))
+	}
+
+	sort.Slice(videos, func(i, j int) bool { return videos[i].Height < videos[j].Height })
+
+	return videos, audios, nil
+}
+
+// audioTrack set the group and the stream of the audio track named <id>_a<profile>_<stream>,
+// the group gathers the languages transcoded with the same profile
+func audioTrack(t Track, resourceID string, job models.Job) Track {
+	var profile, index int
+	fmt.Sscanf(strings.TrimPrefix(t.Name, fmt.Sprintf("%s_a", resourceID)), "%d_%d", &profile, &index)
+
+	t.Group = fmt.Sprintf("%s_a%d", resourceID, profile)
+	t.Stream = models.AudioStream{Index: index, Language: UndefinedLanguage, Default: index == 0}
+	if s, ok := job.AudioStream(index); ok {
+		t.Stream = s
+	}
+	return t
+}
+
+// probeTrack bind the first stream of the kind found by ffprobe
+func probeTrack(ctx context.Context, filename, kind string) (t Track, err error) {
+	info, err := Probe(ctx, filename)
+	if err != nil {
+		return t, err
+	}
+
+	t.Source = filename
+	t.Name = strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename))
+	t.Bandwidth = info.BitRate
+	t.Duration = info.Duration.Seconds()
+
+	var bitRate int
+	switch {
+	case kind == "video" && info.Video != nil:
+		v := info.Video
+		t.Width, t.Height, t.Codec, bitRate = v.Width, v.Height, v.Codec, v.BitRate
+		t.Codecs = CodecString(v.Codec, v.Profile, v.Level)
+	case kind == "audio" && len(info.Audios) > 0:
+		a := info.Audios[0]
+		t.Codec, bitRate = a.Codec, a.BitRate
+		t.Codecs = CodecString(a.Codec, a.Profile, 0)
+	default:
+		return t, fmt.Errorf("%s without %s stream", filename, kind)
+	}
+
+	if bitRate > 0 {
+		t.Bandwidth = bitRate
+	}
+	t.AverageBandwidth = t.Bandwidth
+
+	var stdout bytes.Buffer
+	err = runCommand(ctx, &stdout, "ffprobe", "-v", "error", "-select_streams", fmt.Sprintf("%c:0", kind[0]),
+		"-show_entries", "packet=dts_time,size", "-of", "csv=p=0", filename)
+	if err != nil {
+		return t, err
+	}
+	if peak := PeakBitrate(stdout.String(), float64(settings.PackagingSetting.SegmentDuration)); peak > t.Bandwidth {
+		t.Bandwidth = peak
+	}
+	return t, nil
+}
+
+// PeakBitrate return the highest bitrate in bits per second of the windows of the segment duration
+// over the packets listed by ffprobe as dts_time,size
+func PeakBitrate(output string, window float64) int {
+	if window <= 0 {
+		return 0
+	}
+
+	sizes := map[int]int{}
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		fields := strings.Split(strings.TrimSpace(scanner.Text()), ",")
+		if len(fields) < 2 {
+			continue
+		}
+		dts, errDTS := strconv.ParseFloat(fields[0], 64)
+		size, errSize := strconv.Atoi(fields[1])
+		if errDTS != nil || errSize != nil {
+			continue
+		}
+		sizes[int(math.Floor(dts/window))] += size
+	}
+
+	var peak int
+	for _, size := range sizes {
+		if bits := int(float64(size*8) / window); bits > peak {
+			peak = bits
+		}
+	}
+	return peak
+}
+
+// PackageHLS segment the renditions and the audio of the resource and write the master playlist
+func (c *Client) PackageHLS(ctx context.Context, dir, resourceID string) error {
+	dst := filepath.Join(dir, HLSDir)
+	os.MkdirAll(dst, 0777)
+
+	videos, audios, err := Tracks(ctx, dir, resourceID)
+	if err != nil {
+		return err
+	}
+
+	// the ts segments only carry h264 and aac, the other codecs are delivered by dash and cmaf
+	videos = filterCodec(videos, "h264")
+	audios = filterCodec(audios, "aac")
+
+	for _, t := range append(videos, audios...) {
+		if err := c.SegmentHLS(ctx, t.Source, dst, t.Name); err != nil {
+			return err
+		}
+	}
+
+	subs := Subtitles(dir, resourceID)
+	if len(videos) > 0 {
+		if err := WriteSubtitlePlaylists(dst, subs, videos[0].Duration); err != nil {
+			return err
+		}
+	}
+
+	return ioutil.WriteFile(filepath.Join(dst, "master.m3u8"), []byte(MasterPlaylist(videos, audios, subs)), 0644)
+}
+
+// filterCodec return the tracks encoded with the codec
+func filterCodec(tracks []Track, codec string) []Track {
+	var filtered []Track
+	for _, t := range tracks {
+		if t.Codec == codec {
+			filtered = append(filtered, t)
+		}
+	}
+	return filtered
+}
+
+// SegmentHLS generate the vod playlist and the ts segments from a single track
+func (c *Client) SegmentHLS(ctx context.Context, filename, dst, name string) error {
+	return runCommand(ctx, nil, "ffmpeg", "-hide_banner", "-y", "-i", filename, "-c", "copy",
+		"-f", "hls", "-hls_time", strconv.Itoa(settings.PackagingSetting.SegmentDuration),
+		"-hls_playlist_type", "vod", "-hls_flags", "independent_segments",
+		"-hls_segment_filename", filepath.Join(dst, fmt.Sprintf("%s_%%05d.ts", name)),
+		filepath.Join(dst, fmt.Sprintf("%s.m3u8", name)))
+}
+
+// MasterPlaylist return the master playlist, each audio profile is an audio group with one
+// rendition per language and every video rendition is listed once to each group, the
+// subtitles are a single group referenced by every variant, BANDWIDTH is the peak of the variant
+// and AVERAGE-BANDWIDTH its average
+func MasterPlaylist(videos, audios []Track, subs []models.Subtitle) string {
+	var b strings.Builder
+
+	b.WriteString("#EXTM3U\n")
+	b.WriteString("#EXT-X-VERSION:3\n")
+	b.WriteString("#EXT-X-INDEPENDENT-SEGMENTS\n")
+
+	var groups []Track
+	bandwidths, averages := map[string]int{}, map[string]int{}
+	for _, a := range audios {
+		if _, ok := bandwidths[a.Group]; !ok {
+			groups = append(groups, a)
+		}
+		if a.Bandwidth > bandwidths[a.Group] {
+			bandwidths[a.Group] = a.Bandwidth
+		}
+		if a.AverageBandwidth > averages[a.Group] {
+			averages[a.Group] = a.AverageBandwidth
+		}
+		b.WriteString(audioMedia(a))
+	}
+
+	var subtitles string
+	for _, s := range subs {
+		b.WriteString(subtitleMedia(s))
+		subtitles = fmt.Sprintf(",SUBTITLES=\"%s\"", SubtitleGroup)
+	}
+
+	for _, v := range videos {
+		if len(groups) == 0 {
+			fmt.Fprintf(&b, "#EXT-X-STREAM-INF:BANDWIDTH=%d,AVERAGE-BANDWIDTH=%d,RESOLUTION=%dx%d,CODECS=\"%s\"%s\n%s.m3u8\n",
+				v.Bandwidth, v.AverageBandwidth, v.Width, v.Height, v.Codecs, subtitles, v.Name)
+			continue
+		}
+
+		for _, g := range groups {
+			fmt.Fprintf(&b, "#EXT-X-STREAM-INF:BANDWIDTH=%d,AVERAGE-BANDWIDTH=%d,RESOLUTION=%dx%d,CODECS=\"%s,%s\",AUDIO=\"%s\"%s\n%s.m3u8\n",
+				v.Bandwidth+bandwidths[g.Group], v.AverageBandwidth+averages[g.Group], v.Width, v.Height, v.Codecs, g.Codecs, g.Group, subtitles, v.Name)
+		}
+	}
+
+	return b.String()
+}
+
+// audioMedia return the EXT-X-MEDIA of the audio track, the commentaries aren't selected
+// automatically and the audio descriptions are flagged by their characteristics
+func audioMedia(a Track) string {
+	name := a.Stream.Language
+	if a.Stream.Commentary {
+		name = fmt.Sprintf("%s commentary", name)
+	}
+	if a.Stream.Descriptive {
+		name = fmt.Sprintf("%s description", name)
+	}
+
+	def, autoselect := "NO", "YES"
+	if a.Stream.Default {
+		def = "YES"
+	}
+	if a.Stream.Commentary {
+		autoselect = "NO"
+	}
+
+	attrs := fmt.Sprintf("TYPE=AUDIO,GROUP-ID=\"%s\",NAME=\"%s (%d)\",DEFAULT=%s,AUTOSELECT=%s",
+		a.Group, name, a.Stream.Index, def, autoselect)
+	if a.Stream.Language != UndefinedLanguage {
+		attrs = fmt.Sprintf("%s,LANGUAGE=\"%s\"", attrs, a.Stream.Language)
+	}
+	if a.Stream.Descriptive {
+		attrs = fmt.Sprintf("%s,CHARACTERISTICS=\"public.accessibility.describes-video\"", attrs)
+	}
+
+	return fmt.Sprintf("#EXT-X-MEDIA:%s,URI=\"%s.m3u8\"\n", attrs, a.Name)
+}
@@ -0,0 +1,79 @@
+package ffmpeg
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPeakBitrate(t *testing.T) {
+	tests := []struct {
+		name     string
+		output   string
+		window   float64
+		expected int
+	}{
+		{
+			name:     "constant",
+			output:   "0.000000,1000\n1.000000,1000\n2.000000,1000\n3.000000,1000\n",
+			window:   2,
+			expected: 8000,
+		},
+		{
+			name:     "peak on the second window",
+			output:   "0.000000,1000\n1.000000,1000\n2.000000,5000\n3.000000,3000\n4.000000,500\n",
+			window:   2,
+			expected: 32000,
+		},
+		{
+			name:     "packets without timestamp are skipped",
+			output:   "N/A,9000\n0.000000,1000\n",
+			window:   1,
+			expected: 8000,
+		},
+		{
+			name:   "without packets",
+			window: 6,
+		},
+		{
+			name:   "without window",
+			output: "0.000000,1000\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if peak := PeakBitrate(tt.output, tt.window); peak != tt.expected {
+				t.Errorf("expected %d, got %d", tt.expected, peak)
+			}
+		})
+	}
+}
+
+func TestMasterPlaylistBandwidth(t *testing.T) {
+	videos := []Track{{Name: "id_v3", Bandwidth: 900000, AverageBandwidth: 600000, Width: 1280, Height: 720, Codecs: "avc1.4d401f"}}
+	audios := []Track{{Name: "id_a0_0", Bandwidth: 130000, AverageBandwidth: 128000, Codecs: "mp4a.40.2", Group: "id_a0"}}
+
+	tests := []struct {
+		name     string
+		audios   []Track
+		expected string
+	}{
+		{
+			name:     "video only",
+			expected: "BANDWIDTH=900000,AVERAGE-BANDWIDTH=600000,",
+		},
+		{
+			name:     "audio group added to the variant",
+			audios:   audios,
+			expected: "BANDWIDTH=1030000,AVERAGE-BANDWIDTH=728000,",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if playlist := MasterPlaylist(videos, tt.audios, nil); !strings.Contains(playlist, tt.expected) {
+				t.Errorf("expected %s on\n%s", tt.expected, playlist)
+			}
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package ffmpeg
+
+import (
+	"math"
+
+	"github.com/Voodfy/voodfy-transcoder/internal/models"
+	"github.com/Voodfy/voodfy-transcoder/internal/settings"
+)
+
+// PlanLadder return the profiles that don't upscale the source, the height of a profile
+// is applied to the short side so portrait videos keep the same quality of the landscape,
+// the long side follows the display aspect ratio rounded to an even value
+func PlanLadder(info models.MediaInfo, profiles []settings.RenditionProfile) []settings.RenditionProfile {
+	if info.Video == nil || len(profiles) == 0 {
+		return profiles
+	}
+
+	width, height := info.Video.DisplayWidth, info.Video.DisplayHeight
+
+	short, long := height, width
+	if width < height {
+		short, long = width, height
+	}
+
+	var planned []settings.RenditionProfile
+	for _, p := range profiles {
+		if p.Height <= short {
+			planned = append(planned, fit(p, p.Height, short, long, width < height))
+		}
+	}
+
+	// the source is smaller than the ladder, keep the lowest rendition on the source size
+	if len(planned) == 0 {
+		lowest := profiles[0]
+		for _, p := range profiles {
+			if p.Height < lowest.Height {
+				lowest = p
+			}
+		}
+		planned = append(planned, fit(lowest, short, short, long, width < height))
+	}
+
+	return planned
+}
+
+// fit set the width and height of the profile keeping the aspect ratio of the source
+func fit(p settings.RenditionProfile, target, short, long int, portrait bool) settings.RenditionProfile {
+	target = even(target)
+	scaled := even(int(math.Round(float64(target) * float64(long) / float64(short))))
+
+	p.Width, p.Height = scaled, target
+	if portrait {
+		p.Width, p.Height = target, scaled
+	}
+	return p
+}
+
+// even round down the value to the nearest even, the encoders reject odd dimensions
+func even(v int) int {
+	if v < 2 {
+		return 2
+	}
+	return v - v%2
+}
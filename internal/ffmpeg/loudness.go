@@ -0,0 +1,84 @@
+package ffmpeg
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/Voodfy/voodfy-transcoder/internal/models"
+	"github.com/Voodfy/voodfy-transcoder/internal/settings"
+)
+
+// loudnormOutput the json written by loudnorm on stderr, the values are strings
+type loudnormOutput struct {
+	InputI       string `json:"input_i"`
+	InputTP      string `json:"input_tp"`
+	InputLRA     string `json:"input_lra"`
+	InputThresh  string `json:"input_thresh"`
+	TargetOffset string `json:"target_offset"`
+}
+
+// AnalyzeLoudness run the first pass of loudnorm over the audio stream and return the measurement
+func (c *Client) AnalyzeLoudness(ctx context.Context, filename string, stream int) (l models.Loudness, err error) {
+	var stderr bytes.Buffer
+
+	err = execute(ctx, nil, &stderr, "ffmpeg", "-hide_banner", "-nostats", "-i", filename,
+		"-map", fmt.Sprintf("0:a:%d", stream), "-af", fmt.Sprintf("%s:print_format=json", loudnormTarget()), "-f", "null", "-")
+	if err != nil {
+		return l, err
+	}
+
+	return ParseLoudness(stderr.Bytes())
+}
+
+// ParseLoudness bind the last json object written by loudnorm
+func ParseLoudness(stderr []byte) (l models.Loudness, err error) {
+	start := bytes.LastIndexByte(stderr, '{')
+	end := bytes.LastIndexByte(stderr, '}')
+	if start < 0 || end < start {
+		return l, fmt.Errorf("ffmpeg: loudnorm measurement not found")
+	}
+
+	var out loudnormOutput
+	if err := json.Unmarshal(stderr[start:end+1], &out); err != nil {
+		return l, err
+	}
+
+	values := []struct {
+		dst *float64
+		src string
+	}{
+		{&l.Integrated, out.InputI},
+		{&l.TruePeak, out.InputTP},
+		{&l.LRA, out.InputLRA},
+		{&l.Threshold, out.InputThresh},
+		{&l.Offset, out.TargetOffset},
+	}
+	for _, v := range values {
+		if *v.dst, err = strconv.ParseFloat(v.src, 64); err != nil {
+			return l, fmt.Errorf("ffmpeg: invalid loudnorm value %q: %w", v.src, err)
+		}
+	}
+
+	return l, nil
+}
+
+// LoudnormFilter return the second pass of loudnorm using the measurement, the filter is
+// empty when the source is silent because loudnorm can't be applied over -inf
+func LoudnormFilter(l *models.Loudness) string {
+	if l == nil || math.IsInf(l.Integrated, 0) || math.IsInf(l.Threshold, 0) {
+		return ""
+	}
+
+	return fmt.Sprintf("%s:measured_I=%.2f:measured_TP=%.2f:measured_LRA=%.2f:measured_thresh=%.2f:offset=%.2f:linear=true",
+		loudnormTarget(), l.Integrated, l.TruePeak, l.LRA, l.Threshold, l.Offset)
+}
+
+// loudnormTarget return the loudnorm filter with the targets of the setting
+func loudnormTarget() string {
+	cfg := settings.LoudnormSetting
+	return fmt.Sprintf("loudnorm=I=%.1f:TP=%.1f:LRA=%.1f", cfg.Integrated, cfg.TruePeak, cfg.LRA)
+}
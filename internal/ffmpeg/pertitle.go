@@ -0,0 +1,137 @@
+package ffmpeg
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/Voodfy/voodfy-transcoder/internal/settings"
+)
+
+// PerTitle replace the bitrates of the ladder by the average of the crf test encodes
+// made on samples spread over the source, the result is limited by the factors of the setting
+func PerTitle(ctx context.Context, cmd Commands, filename string, duration float64, ladder []settings.RenditionProfile) []settings.RenditionProfile {
+	cfg := settings.PerTitleSetting
+	positions, length := samplePositions(duration, cfg.Samples, cfg.SampleDuration)
+
+	var planned []settings.RenditionProfile
+	for _, p := range ladder {
+		var total, measured int
+		for _, position := range positions {
+			kbps, err := cmd.SampleBitrate(ctx, filename, p, position, length)
+			if err == nil {
+				total += kbps
+				measured++
+			}
+		}
+
+		if measured > 0 {
+			p = applyBitrate(p, total/measured)
+		}
+		planned = append(planned, p)
+	}
+
+	return planned
+}
+
+// SampleBitrate encode a sample of the source with the crf of the analysis and return its bitrate in kbps,
+// the sample is written on the container of the rendition
+func (c *Client) SampleBitrate(ctx context.Context, filename string, p settings.RenditionProfile, position, length float64) (int, error) {
+	tmp, err := ioutil.TempFile("", fmt.Sprintf("pertitle_*.%s", p.Extension()))
+	if err != nil {
+		return 0, err
+	}
+	tmp.Close()
+	defer os.Remove(tmp.Name())
+
+	args := []string{"-hide_banner", "-y", "-ss", fmt.Sprintf("%.3f", position), "-t", fmt.Sprintf("%.3f", length),
+		"-i", filename, "-vf", scaleFilter(p), "-c:v", p.Codec}
+	if p.Profile != "" {
+		args = append(args, "-profile:v", p.Profile)
+	}
+	args = append(args, SampleArgs(p)...)
+	args = append(args, "-an", tmp.Name())
+
+	if err := runCommand(ctx, nil, "ffmpeg", args...); err != nil {
+		return 0, err
+	}
+
+	info, err := os.Stat(tmp.Name())
+	if err != nil {
+		return 0, err
+	}
+
+	if length <= 0 {
+		return 0, fmt.Errorf("ffmpeg: invalid sample length %f", length)
+	}
+
+	return int(float64(info.Size()) * 8 / length / 1000), nil
+}
+
+// SampleArgs return the rate control of the sample, the constant quality of the analysis
+// with the flags each encoder needs to it
+func SampleArgs(p settings.RenditionProfile) []string {
+	sample := p
+	sample.RateControl = settings.RateControlCRF
+	sample.CRF = settings.PerTitleSetting.CRF
+	return RateControlArgs(sample)
+}
+
+// samplePositions return the start of each sample centered on equal parts of the source
+func samplePositions(duration float64, samples int, length float64) ([]float64, float64) {
+	if samples < 1 {
+		samples = 1
+	}
+
+	if duration <= length*float64(samples) {
+		return []float64{0}, math.Max(duration, 1)
+	}
+
+	var positions []float64
+	for i := 0; i < samples; i++ {
+		center := duration * (float64(i) + 0.5) / float64(samples)
+		positions = append(positions, math.Max(center-length/2, 0))
+	}
+	return positions, length
+}
+
+// applyBitrate set the bitrate measured limited by the factors over the bitrate of the ladder
+func applyBitrate(p settings.RenditionProfile, kbps int) settings.RenditionProfile {
+	cfg := settings.PerTitleSetting
+
+	if base := Kbps(p.Bitrate); base > 0 {
+		kbps = int(math.Min(math.Max(float64(kbps), float64(base)*cfg.MinFactor), float64(base)*cfg.MaxFactor))
+	}
+
+	maxrate := int(float64(kbps) * cfg.MaxrateFactor)
+
+	p.Bitrate = fmt.Sprintf("%dk", kbps)
+	p.Maxrate = fmt.Sprintf("%dk", maxrate)
+	p.Bufsize = fmt.Sprintf("%dk", maxrate*2)
+	return p
+}
+
+// Kbps parse the bitrates used by ffmpeg like 284k or 1.5M
+func Kbps(value string) int {
+	value = strings.TrimSpace(strings.ToLower(value))
+	factor := 0.001
+
+	switch {
+	case strings.HasSuffix(value, "k"):
+		factor = 1
+		value = strings.TrimSuffix(value, "k")
+	case strings.HasSuffix(value, "m"):
+		factor = 1000
+		value = strings.TrimSuffix(value, "m")
+	}
+
+	v, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0
+	}
+	return int(v * factor)
+}
@@ -0,0 +1,275 @@
+package ffmpeg
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/Voodfy/voodfy-transcoder/internal/models"
+	"github.com/Voodfy/voodfy-transcoder/internal/settings"
+	"github.com/Voodfy/voodfy-transcoder/internal/utils"
+)
+
+// SelectPoster extract the candidates spread over the source and return the sharpest one with
+// a balanced brightness, the black, white and blurry frames are only used when every candidate is
+func (c *Client) SelectPoster(ctx context.Context, filename string, duration float64) (models.Poster, error) {
+	cfg := settings.PosterSetting
+
+	tmp, err := ioutil.TempDir("", "poster_")
+	if err != nil {
+		return models.Poster{}, err
+	}
+	defer os.RemoveAll(tmp)
+
+	var candidates []models.Poster
+	for i := 0; i < cfg.Candidates; i++ {
+		position := duration * float64(i+1) / float64(cfg.Candidates+1)
+		dst := filepath.Join(tmp, fmt.Sprintf("candidate_%02d.jpg", i))
+
+		err := runCommand(ctx, nil, "ffmpeg", "-hide_banner", "-y", "-ss", fmt.Sprintf("%.3f", position), "-i", filename,
+			"-frames:v", "1", "-vf", "scale=320:-2", dst)
+		if err != nil {
+			if ctx.Err() != nil {
+				return models.Poster{}, err
+			}
+			continue
+		}
+
+		img, err := decodeJPEG(dst)
+		if err != nil {
+			continue
+		}
+
+		brightness, sharpness := FrameScore(img)
+		candidates = append(candidates, models.Poster{Position: position, Brightness: brightness, Sharpness: sharpness})
+	}
+
+	if len(candidates) == 0 {
+		return models.Poster{}, fmt.Errorf("ffmpeg: no poster candidate extracted from %s", filename)
+	}
+
+	return ChoosePoster(candidates, cfg), nil
+}
+
+// ChoosePoster return the candidate with the best score between the accepted ones, or between
+// all of them when none is accepted
+func ChoosePoster(candidates []models.Poster, cfg *settings.Poster) models.Poster {
+	var accepted []models.Poster
+	for _, p := range candidates {
+		if p.Brightness >= cfg.MinBrightness && p.Brightness <= cfg.MaxBrightness && p.Sharpness >= cfg.MinSharpness {
+			accepted = append(accepted, p)
+		}
+	}
+
+	if len(accepted) == 0 {
+		accepted = candidates
+	}
+
+	best := accepted[0]
+	for _, p := range accepted[1:] {
+		if posterScore(p) > posterScore(best) {
+			best = p
+		}
+	}
+	return best
+}
+
+// posterScore favour the sharp frames with brightness close to the middle
+func posterScore(p models.Poster) float64 {
+	return p.Sharpness * (1 - math.Abs(p.Brightness-0.5))
+}
+
+// FrameScore return the average luma between 0 and 1 and the variance of the laplacian of the luma
+func FrameScore(img image.Image) (brightness, sharpness float64) {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w == 0 || h == 0 {
+		return 0, 0
+	}
+
+	luma := make([]float64, w*h)
+	var total float64
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			v := float64(color.GrayModel.Convert(img.At(b.Min.X+x, b.Min.Y+y)).(color.Gray).Y)
+			luma[y*w+x] = v
+			total += v
+		}
+	}
+	brightness = total / float64(w*h) / 255
+
+	if w < 3 || h < 3 {
+		return brightness, 0
+	}
+
+	var sum, sumSq float64
+	n := float64((w - 2) * (h - 2))
+	for y := 1; y < h-1; y++ {
+		for x := 1; x < w-1; x++ {
+			l := luma[(y-1)*w+x] + luma[(y+1)*w+x] + luma[y*w+x-1] + luma[y*w+x+1] - 4*luma[y*w+x]
+			sum += l
+			sumSq += l * l
+		}
+	}
+	mean := sum / n
+	sharpness = sumSq/n - mean*mean
+
+	return brightness, sharpness
+}
+
+// decodeJPEG open and decode the jpeg
+func decodeJPEG(filename string) (image.Image, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return jpeg.Decode(f)
+}
+
+// poster generate the poster at the position given by the fourth argument or at the one selected,
+// the position is recorded on the job when the job id is given
+func poster(ctx context.Context, cmd Commands, args ...string) error {
+	info, err := Probe(ctx, args[0])
+	if err != nil {
+		return err
+	}
+	d := info.Duration.Seconds()
+
+	var p models.Poster
+	if len(args) > 3 && args[3] != "" {
+		position, err := strconv.ParseFloat(args[3], 64)
+		if err != nil {
+			return fmt.Errorf("ffmpeg: invalid poster position %s: %w", args[3], err)
+		}
+		p = models.Poster{Position: position, Override: true}
+	} else if p, err = cmd.SelectPoster(ctx, args[0], d); err != nil {
+		if ctx.Err() != nil {
+			return err
+		}
+		p = models.Poster{Position: defaultPosterPosition(d)}
+	}
+
+	if err := cmd.GenerateImageFromFrameVideo(ctx, args[0], args[1], p.Position); err != nil {
+		return err
+	}
+
+	if err := cmd.GeneratePosterVariants(ctx, args[1], p.Position); err != nil {
+		return err
+	}
+
+	if len(args) > 2 {
+		job := models.Job{ID: args[2]}
+		job.Get()
+		job.Poster = &p
+		job.Save()
+	}
+	return nil
+}
+
+// defaultPosterPosition return the fifth second, or the first one to the short sources
+func defaultPosterPosition(duration float64) float64 {
+	if duration >= 5.0 {
+		return 5
+	}
+	return 1
+}
+
+// PosterIndexName index of the poster variants written next to the poster
+const PosterIndexName = "posters.json"
+
+// posterTypes mime type of each format of the poster variants
+var posterTypes = map[string]string{
+	"jpg":  "image/jpeg",
+	"webp": "image/webp",
+	"avif": "image/avif",
+}
+
+// PosterIndex struct used to describe the poster variants to the players
+type PosterIndex struct {
+	Position float64         `json:"position"`
+	Default  string          `json:"default"`
+	Width    int             `json:"width"`
+	Height   int             `json:"height"`
+	Variants []PosterVariant `json:"variants"`
+}
+
+// PosterVariant struct used to describe a poster resized and encoded on a format
+type PosterVariant struct {
+	File   string `json:"file"`
+	Type   string `json:"type"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+}
+
+// GeneratePosterVariants write the poster on each width and format of the setting and the
+// posters.json that lists them, the widths above the poster are skipped and a format the
+// ffmpeg build can't encode is logged and left out of the index
+func (c *Client) GeneratePosterVariants(ctx context.Context, dstFile string, position float64) error {
+	src := fmt.Sprintf("%sposter.jpg", dstFile)
+	r, err := Execute(ctx, src)
+	if err != nil {
+		return err
+	}
+
+	// a still image has no duration, only the video stream is normalized
+	info, _ := r.MediaInfo()
+	if info.Video == nil {
+		return fmt.Errorf("ffmpeg: %s without image", src)
+	}
+	index := PosterIndex{Position: position, Default: "poster.jpg", Width: info.Video.Width, Height: info.Video.Height}
+
+	failed := map[string]bool{}
+	for _, width := range settings.PosterSetting.Widths {
+		if width > index.Width {
+			continue
+		}
+		height := even(int(math.Round(float64(width) * float64(index.Height) / float64(index.Width))))
+
+		for _, format := range settings.PosterSetting.Formats {
+			if failed[format] {
+				continue
+			}
+
+			variant := PosterVariant{File: fmt.Sprintf("poster_%d.%s", width, format), Type: posterTypes[format], Width: width, Height: height}
+			args := append([]string{"-hide_banner", "-y", "-i", src}, posterArgs(variant, format)...)
+			if err := runCommand(ctx, nil, "ffmpeg", append(args, fmt.Sprintf("%s%s", dstFile, variant.File))...); err != nil {
+				if ctx.Err() != nil {
+					return err
+				}
+				utils.SendError(fmt.Sprintf("ffmpeg.GeneratePosterVariants.%s", format), err)
+				failed[format] = true
+				continue
+			}
+			index.Variants = append(index.Variants, variant)
+		}
+	}
+
+	content, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(fmt.Sprintf("%s%s", dstFile, PosterIndexName), content, 0644)
+}
+
+// posterArgs return the output options of the variant on the format
+func posterArgs(v PosterVariant, format string) []string {
+	args := []string{"-vf", fmt.Sprintf("scale=%d:%d", v.Width, v.Height), "-frames:v", "1"}
+
+	switch format {
+	case "webp":
+		return append(args, "-c:v", "libwebp", "-quality", "75")
+	case "avif":
+		return append(args, "-c:v", "libaom-av1", "-still-picture", "1", "-crf", "32", "-pix_fmt", "yuv420p")
+	}
+	return append(args, "-q:v", "4")
+}
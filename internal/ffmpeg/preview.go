@@ -0,0 +1,65 @@
+package ffmpeg
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/Voodfy/voodfy-transcoder/internal/settings"
+)
+
+// GenerateWebpFromFrameVideo generate a looping webp, and a gif when it is enabled, joining
+// highlights spread over the source, the clip keeps the aspect ratio of the source
+func (c *Client) GenerateWebpFromFrameVideo(ctx context.Context, filename, dstFile string, duration time.Duration) error {
+	cfg := settings.PreviewSetting
+	inputs, segments := previewInputs(filename, duration.Seconds(), cfg)
+
+	args := append([]string{"-hide_banner", "-y"}, inputs...)
+	args = append(args, "-filter_complex", PreviewFilter(segments, cfg), "-map", "[clip]", "-an",
+		"-c:v", "libwebp", "-lossless", "0", "-quality", "60", "-loop", "0", fmt.Sprintf("%spreview.webp", dstFile))
+	if err := runCommand(ctx, nil, "ffmpeg", args...); err != nil {
+		return err
+	}
+
+	if !cfg.GIF {
+		return nil
+	}
+
+	args = append([]string{"-hide_banner", "-y"}, inputs...)
+	args = append(args, "-filter_complex", fmt.Sprintf("%s;[clip]split[a][b];[a]palettegen[p];[b][p]paletteuse[gif]", PreviewFilter(segments, cfg)),
+		"-map", "[gif]", "-an", "-loop", "0", fmt.Sprintf("%spreview.gif", dstFile))
+	return runCommand(ctx, nil, "ffmpeg", args...)
+}
+
+// previewInputs return an input seeked to each highlight and the number of highlights, the
+// length of the highlights is reduced to keep the clip below the max duration
+func previewInputs(filename string, duration float64, cfg *settings.Preview) ([]string, int) {
+	length := cfg.SegmentDuration
+	if cfg.Segments > 0 && length*float64(cfg.Segments) > cfg.MaxDuration {
+		length = cfg.MaxDuration / float64(cfg.Segments)
+	}
+
+	positions, length := samplePositions(duration, cfg.Segments, length)
+	length = math.Min(length, cfg.MaxDuration)
+
+	var args []string
+	for _, position := range positions {
+		args = append(args, "-ss", fmt.Sprintf("%.3f", position), "-t", fmt.Sprintf("%.3f", length), "-i", filename)
+	}
+	return args, len(positions)
+}
+
+// PreviewFilter return the filter that fits each highlight on the max size keeping the
+// aspect ratio and joins them on the [clip] output
+func PreviewFilter(segments int, cfg *settings.Preview) string {
+	var filters, labels []string
+	for i := 0; i < segments; i++ {
+		filters = append(filters, fmt.Sprintf("[%d:v]fps=%d,scale='min(%d,iw)':'min(%d,ih)':force_original_aspect_ratio=decrease,setsar=1[v%d]",
+			i, cfg.FPS, cfg.MaxWidth, cfg.MaxHeight, i))
+		labels = append(labels, fmt.Sprintf("[v%d]", i))
+	}
+
+	return fmt.Sprintf("%s;%sconcat=n=%d:v=1:a=0[clip]", strings.Join(filters, ";"), strings.Join(labels, ""), segments)
+}
@@ -0,0 +1,23 @@
+//go:build !windows
+// +build !windows
+
+package ffmpeg
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// command return the command running on its own process group, so the children die with it
+func command(name string, args ...string) *exec.Cmd {
+	cmd := exec.Command(name, args...)
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	return cmd
+}
+
+// kill send SIGKILL to the process group of the command
+func kill(cmd *exec.Cmd) {
+	if cmd.Process != nil {
+		syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+}
@@ -0,0 +1,20 @@
+//go:build windows
+// +build windows
+
+package ffmpeg
+
+import (
+	"os/exec"
+)
+
+// command return the command, windows doesn't have process groups
+func command(name string, args ...string) *exec.Cmd {
+	return exec.Command(name, args...)
+}
+
+// kill terminate the process of the command
+func kill(cmd *exec.Cmd) {
+	if cmd.Process != nil {
+		cmd.Process.Kill()
+	}
+}
@@ -0,0 +1,68 @@
+package ffmpeg
+
+import (
+	"bufio"
+	"io"
+	"io/ioutil"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Voodfy/voodfy-transcoder/internal/models"
+)
+
+// Reporter receive the progress of a command each time ffmpeg writes a block
+type Reporter func(models.Progress)
+
+// progressArgs make ffmpeg write the progress on stdout instead of the stats on stderr
+var progressArgs = []string{"-progress", "pipe:1", "-nostats"}
+
+// ParseProgress read the key=value blocks written by -progress
+func ParseProgress(r io.Reader, report Reporter) {
+	var p models.Progress
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		kv := strings.SplitN(strings.TrimSpace(scanner.Text()), "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+
+		switch kv[0] {
+		// out_time_ms is written in microseconds as well
+		case "out_time_us", "out_time_ms":
+			if us, err := strconv.ParseInt(kv[1], 10, 64); err == nil {
+				p.OutTime = (time.Duration(us) * time.Microsecond).Seconds()
+			}
+		case "fps":
+			p.FPS, _ = strconv.ParseFloat(kv[1], 64)
+		case "speed":
+			p.Speed = strings.TrimSpace(kv[1])
+		case "progress":
+			p.Done = kv[1] == "end"
+			if report != nil {
+				report(p)
+			}
+		}
+	}
+
+	// keep draining so ffmpeg never blocks writing the progress
+	io.Copy(ioutil.Discard, r)
+}
+
+// StoreProgress return a reporter that save the progress of the task on redis,
+// the percent is calculated against the probed duration of the source
+func StoreProgress(id, task string, duration time.Duration) Reporter {
+	return func(p models.Progress) {
+		p.ID = id
+		p.Task = task
+		if duration > 0 {
+			p.Percent = math.Min(p.OutTime/duration.Seconds()*100, 100)
+		}
+		if p.Done {
+			p.Percent = 100
+		}
+		p.Save()
+	}
+}
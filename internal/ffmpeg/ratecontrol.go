@@ -0,0 +1,121 @@
+package ffmpeg
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/Voodfy/voodfy-transcoder/internal/settings"
+)
+
+// encode run the arguments of the rendition, the two-pass profiles run the analysis pass first
+// without progress, its stats are written on a directory of its own so the encodes of the
+// workers running on the same host never share a stats file
+func encode(ctx context.Context, report Reporter, p settings.RenditionProfile, args []string) error {
+	if p.Mode() != settings.RateControlTwoPass {
+		return runWithProgress(ctx, report, "ffmpeg", args...)
+	}
+
+	tmp, err := ioutil.TempDir("", "2pass_")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+
+	logfile := filepath.Join(tmp, "stats")
+	if err := runCommand(ctx, nil, "ffmpeg", PassArgs(args, p, 1, logfile)...); err != nil {
+		return err
+	}
+	return runWithProgress(ctx, report, "ffmpeg", PassArgs(args, p, 2, logfile)...)
+}
+
+// RateControlArgs return the arguments of the rate control of the profile, libvpx-vp9 and
+// libaom-av1 take the cap of the constrained quality on -b:v and need -b:v 0 to the constant quality,
+// a profile without rate control is left to the defaults of the encoder
+func RateControlArgs(p settings.RenditionProfile) []string {
+	constrained := p.Codec == "libvpx-vp9" || p.Codec == "libaom-av1"
+
+	switch p.Mode() {
+	case settings.RateControlCRF:
+		args := []string{"-crf", strconv.Itoa(p.CRF)}
+		if constrained {
+			args = append(args, "-b:v", "0")
+		}
+		return args
+	case settings.RateControlCappedCRF:
+		args := []string{"-crf", strconv.Itoa(p.CRF)}
+
+		maxrate := p.Maxrate
+		if maxrate == "" {
+			maxrate = p.Bitrate
+		}
+		if maxrate == "" {
+			return args
+		}
+		if constrained {
+			return append(args, "-b:v", maxrate)
+		}
+
+		bufsize := p.Bufsize
+		if bufsize == "" {
+			bufsize = fmt.Sprintf("%dk", Kbps(maxrate)*2)
+		}
+		return append(args, "-maxrate", maxrate, "-bufsize", bufsize)
+	case settings.RateControlTwoPass:
+		args := []string{"-b:v", p.Bitrate}
+		if p.Maxrate != "" {
+			args = append(args, "-maxrate", p.Maxrate)
+		}
+		if p.Bufsize != "" {
+			args = append(args, "-bufsize", p.Bufsize)
+		}
+		return args
+	}
+
+	return nil
+}
+
+// PassArgs return the arguments of a pass of the two-pass encode, the first pass writes the
+// stats on the logfile and drops the output so the options of the muxer are removed, libx265
+// takes the pass on its own params
+func PassArgs(args []string, p settings.RenditionProfile, pass int, logfile string) []string {
+	dst := args[len(args)-1]
+
+	var out []string
+	for idx := 0; idx < len(args)-1; idx++ {
+		if pass == 1 && args[idx] == "-movflags" {
+			idx++
+			continue
+		}
+		out = append(out, args[idx])
+	}
+
+	if p.Codec == "libx265" {
+		params := fmt.Sprintf("pass=%d:stats=%s", pass, logfile)
+		if idx := indexOf(out, "-x265-params"); idx >= 0 && idx+1 < len(out) {
+			out[idx+1] = fmt.Sprintf("%s:%s", out[idx+1], params)
+		} else {
+			out = append(out, "-x265-params", params)
+		}
+	} else {
+		out = append(out, "-pass", strconv.Itoa(pass), "-passlogfile", logfile)
+	}
+
+	if pass == 1 {
+		return append(out, "-f", "null", os.DevNull)
+	}
+	return append(out, dst)
+}
+
+// indexOf return the position of the value on the list or -1
+func indexOf(list []string, value string) int {
+	for idx, v := range list {
+		if v == value {
+			return idx
+		}
+	}
+	return -1
+}
@@ -0,0 +1,241 @@
+package ffmpeg
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Voodfy/voodfy-transcoder/internal/models"
+)
+
+// SubtitleGroup group id of the subtitles on the hls master playlists
+const SubtitleGroup = "subs"
+
+// textSubtitleCodecs subtitle codecs that can be converted to webvtt, the bitmap
+// ones like pgs and dvd subtitles are skipped
+var textSubtitleCodecs = map[string]bool{
+	"subrip":   true,
+	"ass":      true,
+	"ssa":      true,
+	"webvtt":   true,
+	"mov_text": true,
+	"text":     true,
+}
+
+// sidecarExtensions subtitle files uploaded next to the source
+var sidecarExtensions = map[string]bool{
+	".srt": true,
+	".ass": true,
+	".ssa": true,
+	".vtt": true,
+}
+
+// PlanSubtitles return the text subtitle streams of the source and the sidecar files named
+// <source name>.<language>.srt, .ass, .ssa or .vtt found next to it, each one is written
+// as <id>_<language>.vtt with a suffix when the language repeats
+func PlanSubtitles(info models.MediaInfo, source, resourceID string) []models.Subtitle {
+	var subtitles []models.Subtitle
+	names := map[string]int{}
+
+	for _, st := range info.Subtitles {
+		if !textSubtitleCodecs[st.Codec] {
+			continue
+		}
+
+		s := models.Subtitle{
+			Index:    st.Index,
+			Source:   source,
+			Language: st.Language,
+			Default:  st.Default,
+			Forced:   st.Forced,
+		}
+		subtitles = append(subtitles, nameSubtitle(s, resourceID, names))
+	}
+
+	base := strings.TrimSuffix(source, filepath.Ext(source))
+	sidecars, _ := filepath.Glob(fmt.Sprintf("%s.*", base))
+	for _, f := range sidecars {
+		ext := strings.ToLower(filepath.Ext(f))
+		if !sidecarExtensions[ext] {
+			continue
+		}
+
+		s := models.Subtitle{
+			Index:    -1,
+			Source:   f,
+			Language: strings.Trim(strings.TrimSuffix(strings.TrimPrefix(f, base), filepath.Ext(f)), "."),
+		}
+		subtitles = append(subtitles, nameSubtitle(s, resourceID, names))
+	}
+
+	return subtitles
+}
+
+// nameSubtitle set the name and the file of the subtitle, the names already used are counted on names
+func nameSubtitle(s models.Subtitle, resourceID string, names map[string]int) models.Subtitle {
+	if s.Language == "" {
+		s.Language = UndefinedLanguage
+	}
+
+	s.Name = s.Language
+	if s.Forced {
+		s.Name = fmt.Sprintf("%s_forced", s.Name)
+	}
+
+	names[s.Name]++
+	if n := names[s.Name]; n > 1 {
+		s.Name = fmt.Sprintf("%s_%d", s.Name, n)
+	}
+
+	s.File = fmt.Sprintf("%s_%s.vtt", resourceID, s.Name)
+	return s
+}
+
+// ExtractSubtitle convert the subtitle stream of the source to webvtt
+func (c *Client) ExtractSubtitle(ctx context.Context, filename, dstFile, name string, index int) error {
+	return runCommand(ctx, nil, "ffmpeg", "-hide_banner", "-y", "-i", filename, "-map", fmt.Sprintf("0:s:%d", index),
+		"-c:s", "webvtt", "-f", "webvtt", fmt.Sprintf("%s_%s.vtt", dstFile, name))
+}
+
+// subtitles convert every subtitle planned to the job, the embedded streams are read from
+// the source and the sidecar files are converted by VTTGenerator
+func subtitles(ctx context.Context, cmd Commands, args ...string) error {
+	job := models.Job{ID: args[2]}
+	job.Get()
+
+	dst := filepath.Join(args[1], job.ID)
+	for _, s := range job.Subtitles {
+		var err error
+		if s.Index < 0 {
+			err = cmd.VTTGenerator(ctx, s.Source, dst, s.Name)
+		} else {
+			err = cmd.ExtractSubtitle(ctx, args[0], dst, s.Name, s.Index)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Subtitles return the subtitles planned to the job that were written on the directory
+func Subtitles(dir, resourceID string) []models.Subtitle {
+	job := models.Job{ID: resourceID}
+	job.Get()
+
+	var written []models.Subtitle
+	for _, s := range job.Subtitles {
+		if _, err := os.Stat(filepath.Join(dir, s.File)); err == nil {
+			written = append(written, s)
+		}
+	}
+	return written
+}
+
+// subtitlePlaylist return the name of the hls playlist of the subtitle
+func subtitlePlaylist(s models.Subtitle) string {
+	return fmt.Sprintf("%s.m3u8", strings.TrimSuffix(s.File, filepath.Ext(s.File)))
+}
+
+// WriteSubtitlePlaylists write to each subtitle a vod playlist with the whole webvtt file
+// as the single segment, the file is referenced on the parent directory
+func WriteSubtitlePlaylists(dst string, subs []models.Subtitle, duration float64) error {
+	for _, s := range subs {
+		playlist := fmt.Sprintf("#EXTM3U\n#EXT-X-VERSION:3\n#EXT-X-TARGETDURATION:%d\n#EXT-X-MEDIA-SEQUENCE:0\n#EXT-X-PLAYLIST-TYPE:VOD\n#EXTINF:%.3f,\n../%s\n#EXT-X-ENDLIST\n",
+			int(math.Ceil(duration)), duration, s.File)
+
+		if err := ioutil.WriteFile(filepath.Join(dst, subtitlePlaylist(s)), []byte(playlist), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// subtitleMedia return the EXT-X-MEDIA of the subtitle
+func subtitleMedia(s models.Subtitle) string {
+	def, forced := "NO", "NO"
+	if s.Default {
+		def = "YES"
+	}
+	if s.Forced {
+		forced = "YES"
+	}
+
+	attrs := fmt.Sprintf("TYPE=SUBTITLES,GROUP-ID=\"%s\",NAME=\"%s\",DEFAULT=%s,AUTOSELECT=YES,FORCED=%s",
+		SubtitleGroup, s.Name, def, forced)
+	if s.Language != UndefinedLanguage {
+		attrs = fmt.Sprintf("%s,LANGUAGE=\"%s\"", attrs, s.Language)
+	}
+
+	return fmt.Sprintf("#EXT-X-MEDIA:%s,URI=\"%s\"\n", attrs, subtitlePlaylist(s))
+}
+
+// AddSubtitlesToMaster add the subtitle group to a master playlist written by ffmpeg
+func AddSubtitlesToMaster(path string, subs []models.Subtitle) error {
+	if len(subs) == 0 {
+		return nil
+	}
+
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var b strings.Builder
+	var added bool
+	for _, line := range strings.Split(strings.TrimRight(string(content), "\n"), "\n") {
+		if strings.HasPrefix(line, "#EXT-X-STREAM-INF:") {
+			if !added {
+				for _, s := range subs {
+					b.WriteString(subtitleMedia(s))
+				}
+				added = true
+			}
+			line = fmt.Sprintf("%s,SUBTITLES=\"%s\"", line, SubtitleGroup)
+		}
+		b.WriteString(line)
+		b.WriteString("\n")
+	}
+
+	return ioutil.WriteFile(path, []byte(b.String()), 0644)
+}
+
+// AddSubtitlesToMPD add an adaptation set to each subtitle at the end of the period of the
+// manifest.mpd written by ffmpeg, the dash muxer doesn't accept webvtt inputs
+func AddSubtitlesToMPD(path string, subs []models.Subtitle) error {
+	if len(subs) == 0 {
+		return nil
+	}
+
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	idx := strings.LastIndex(string(content), "</Period>")
+	if idx < 0 {
+		return fmt.Errorf("ffmpeg: %s without period", path)
+	}
+	idx = strings.LastIndex(string(content[:idx]), "\n") + 1
+
+	var b strings.Builder
+	for _, s := range subs {
+		fmt.Fprintf(&b, "\t\t<AdaptationSet contentType=\"text\" mimeType=\"text/vtt\" lang=\"%s\">\n", s.Language)
+		role := "subtitle"
+		if s.Forced {
+			role = "forced-subtitle"
+		}
+		fmt.Fprintf(&b, "\t\t\t<Role schemeIdUri=\"urn:mpeg:dash:role:2011\" value=\"%s\"/>\n", role)
+		fmt.Fprintf(&b, "\t\t\t<Representation id=\"%s\" bandwidth=\"256\">\n", s.Name)
+		fmt.Fprintf(&b, "\t\t\t\t<BaseURL>../%s</BaseURL>\n", s.File)
+		b.WriteString("\t\t\t</Representation>\n\t\t</AdaptationSet>\n")
+	}
+
+	mpd := string(content[:idx]) + b.String() + string(content[idx:])
+	return ioutil.WriteFile(path, []byte(mpd), 0644)
+}
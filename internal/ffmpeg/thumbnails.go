@@ -0,0 +1,73 @@
+package ffmpeg
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Voodfy/voodfy-transcoder/internal/settings"
+)
+
+const (
+	// SpriteName pattern of the sprite sheets written by ThumbsPreviewGenerator
+	SpriteName = "thumbspreview_%03d.jpg"
+	// ThumbnailsVTTName index of the sprite sheets used by the players to the scrub previews
+	ThumbnailsVTTName = "thumbnails.vtt"
+)
+
+// ThumbsPreviewGenerator write the sprite sheets with the tile filter and the thumbnails.vtt
+// that maps each interval of the source to its region on the sheets
+func (c *Client) ThumbsPreviewGenerator(ctx context.Context, filename, dstFile string, duration time.Duration) error {
+	cfg := settings.ThumbnailsSetting
+	err := runCommand(ctx, nil, "ffmpeg", "-hide_banner", "-y", "-i", filename, "-an", "-sn",
+		"-vf", SpriteFilter(cfg), "-vsync", "vfr", "-q:v", "3", filepath.Join(dstFile, SpriteName))
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(filepath.Join(dstFile, ThumbnailsVTTName), []byte(ThumbnailsVTT(duration.Seconds(), cfg)), 0644)
+}
+
+// SpriteFilter return the filter that takes the frames of the thumbnails and tiles them on the grid
+func SpriteFilter(cfg *settings.Thumbnails) string {
+	return fmt.Sprintf("%s,tile=%dx%d", frameFilter(cfg.Interval, cfg.Width, cfg.Height), cfg.Columns, cfg.Rows)
+}
+
+// frameFilter return the filter that takes a frame each interval and fits it on the size keeping the aspect ratio
+func frameFilter(interval float64, width, height int) string {
+	return fmt.Sprintf("fps=1/%g,scale=%d:%d:force_original_aspect_ratio=decrease,pad=%d:%d:(ow-iw)/2:(oh-ih)/2",
+		interval, width, height, width, height)
+}
+
+// ThumbnailsVTT return the webvtt that maps each interval to the #xywh region of its sprite sheet
+func ThumbnailsVTT(duration float64, cfg *settings.Thumbnails) string {
+	var b strings.Builder
+	b.WriteString("WEBVTT\n")
+
+	count := int(math.Ceil(duration / cfg.Interval))
+	perSheet := cfg.Columns * cfg.Rows
+
+	for i := 0; i < count; i++ {
+		start := float64(i) * cfg.Interval
+		end := math.Min(start+cfg.Interval, duration)
+
+		pos := i % perSheet
+		x := (pos % cfg.Columns) * cfg.Width
+		y := (pos / cfg.Columns) * cfg.Height
+
+		fmt.Fprintf(&b, "\n%s --> %s\n%s#xywh=%d,%d,%d,%d\n", vttTimestamp(start), vttTimestamp(end),
+			fmt.Sprintf(SpriteName, i/perSheet+1), x, y, cfg.Width, cfg.Height)
+	}
+
+	return b.String()
+}
+
+// vttTimestamp format the seconds as hh:mm:ss.mmm
+func vttTimestamp(seconds float64) string {
+	d := time.Duration(math.Round(seconds*1000)) * time.Millisecond
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", int(d.Hours()), int(d.Minutes())%60, int(d.Seconds())%60, d.Milliseconds()%1000)
+}
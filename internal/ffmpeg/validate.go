@@ -0,0 +1,187 @@
+package ffmpeg
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/Voodfy/voodfy-transcoder/internal/models"
+	"github.com/Voodfy/voodfy-transcoder/internal/mp4"
+	"github.com/Voodfy/voodfy-transcoder/internal/settings"
+)
+
+// ErrInvalidRendition returned when the rendition doesn't pass the validation
+var ErrInvalidRendition = errors.New("ffmpeg: invalid rendition")
+
+// ValidateRendition compare the duration of the rendition with the video stream of the source, its
+// resolution and codec with the profile and decode it looking for errors when the decode pass is enabled
+func (c *Client) ValidateRendition(ctx context.Context, source, output string, p settings.RenditionProfile) error {
+	cfg := settings.ValidationSetting
+
+	s, err := Probe(ctx, source)
+	if err != nil {
+		return err
+	}
+
+	o, err := Probe(ctx, output)
+	if err != nil {
+		return fmt.Errorf("%w: %s can't be probed: %v", ErrInvalidRendition, output, err)
+	}
+
+	sd, od := s.VideoDuration().Seconds(), o.VideoDuration().Seconds()
+	if math.Abs(sd-od) > cfg.DurationTolerance {
+		return fmt.Errorf("%w: duration of %s is %.3fs, expected %.3fs", ErrInvalidRendition, output, od, sd)
+	}
+
+	if err := checkStream(o, p); err != nil {
+		return fmt.Errorf("%w: %s %v", ErrInvalidRendition, output, err)
+	}
+
+	if p.Extension() == "mp4" {
+		if err := checkFastStart(output); err != nil {
+			return fmt.Errorf("%w: %s %v", ErrInvalidRendition, output, err)
+		}
+	}
+
+	if !cfg.Decode {
+		return nil
+	}
+
+	var stderr bytes.Buffer
+	if err := execute(ctx, nil, &stderr, "ffmpeg", "-hide_banner", "-nostats", "-v", "error", "-i", output, "-f", "null", "-"); err != nil {
+		return err
+	}
+	if lines := strings.TrimSpace(stderr.String()); lines != "" {
+		return fmt.Errorf("%w: decoding %s: %s", ErrInvalidRendition, output, strings.SplitN(lines, "\n", 2)[0])
+	}
+
+	return nil
+}
+
+// checkStream compare the video stream of the rendition with the codec and the size of the profile,
+// only the height is compared when the width follows the aspect ratio
+func checkStream(info models.MediaInfo, p settings.RenditionProfile) error {
+	v := info.Video
+	if v == nil {
+		return errors.New("without video stream")
+	}
+
+	if family := CodecFamily(p.Codec); v.Codec != family {
+		return fmt.Errorf("codec is %s, expected %s", v.Codec, family)
+	}
+
+	if v.Height != p.Height || (p.Width > 0 && v.Width != p.Width) {
+		return fmt.Errorf("resolution is %dx%d, expected %dx%d", v.Width, v.Height, p.Width, p.Height)
+	}
+	return nil
+}
+
+// checkFastStart read the boxes of the mp4 rendition, written with -movflags faststart, and
+// return an error when the moov isn't before the mdat or the video track has no sample
+func checkFastStart(filename string) error {
+	info, err := mp4.InspectFile(filename)
+	if err != nil {
+		return err
+	}
+
+	if !info.FastStart {
+		return errors.New("moov written after mdat")
+	}
+
+	if v, ok := info.Video(); !ok || v.Samples == 0 {
+		return errors.New("without video samples")
+	}
+	return nil
+}
+
+// Keyframes return the timestamps in seconds of the keyframes of the first video stream, ffprobe
+// 4.x reports them on pkt_pts_time and the later versions on pts_time, an error is returned
+// when none of the frames gives a timestamp
+func (c *Client) Keyframes(ctx context.Context, filename string) ([]float64, error) {
+	var stdout bytes.Buffer
+
+	err := runCommand(ctx, &stdout, "ffprobe", "-v", "error", "-select_streams", "v:0", "-skip_frame", "nokey",
+		"-show_entries", "frame=pts_time,pkt_pts_time,best_effort_timestamp_time", "-of", "csv=p=0", filename)
+	if err != nil {
+		return nil, err
+	}
+
+	keyframes := ParseKeyframes(stdout.String())
+	if len(keyframes) == 0 {
+		return nil, fmt.Errorf("ffmpeg: keyframes of %s can't be read: %q", filename, firstLine(stdout.String()))
+	}
+	return keyframes, nil
+}
+
+// ParseKeyframes return the first timestamp of each line of the csv written by ffprobe,
+// the fields left empty or N/A are skipped
+func ParseKeyframes(output string) []float64 {
+	var keyframes []float64
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		for _, field := range strings.Split(scanner.Text(), ",") {
+			if t, err := strconv.ParseFloat(strings.TrimSpace(field), 64); err == nil {
+				keyframes = append(keyframes, t)
+				break
+			}
+		}
+	}
+	return keyframes
+}
+
+// firstLine return the first line of the output
+func firstLine(output string) string {
+	return strings.SplitN(strings.TrimSpace(output), "\n", 2)[0]
+}
+
+// AlignedKeyframes return an error when the keyframes differ from the reference beyond the tolerance
+func AlignedKeyframes(reference, keyframes []float64, tolerance float64) error {
+	if len(reference) != len(keyframes) {
+		return fmt.Errorf("%w: %d keyframes, expected %d", ErrInvalidRendition, len(keyframes), len(reference))
+	}
+
+	for idx, t := range keyframes {
+		if math.Abs(t-reference[idx]) > tolerance {
+			return fmt.Errorf("%w: keyframe %d at %.3fs, expected %.3fs", ErrInvalidRendition, idx, t, reference[idx])
+		}
+	}
+	return nil
+}
+
+// validate check the rendition and its keyframes against the first rendition validated to the job,
+// the result is recorded on the job so a failure blocks the upload
+func validate(ctx context.Context, cmd Commands, args ...string) error {
+	p, ok := profile(args[2], args...)
+	if !ok {
+		return fmt.Errorf("ffmpeg: unknown job %s", args[2])
+	}
+
+	job := models.Job{ID: args[3]}
+	job.Get()
+
+	err := cmd.ValidateRendition(ctx, args[0], args[1], p)
+	if err == nil {
+		var keyframes []float64
+		if keyframes, err = cmd.Keyframes(ctx, args[1]); err == nil {
+			if job.Keyframes == nil {
+				job.Keyframes = keyframes
+			}
+			err = AlignedKeyframes(job.Keyframes, keyframes, settings.ValidationSetting.KeyframeTolerance)
+		}
+	}
+
+	// a command killed by the timeout or a cancellation doesn't say anything about the rendition
+	if ctx.Err() != nil {
+		return err
+	}
+
+	job.SetFailure(p.Name, err)
+	job.Save()
+
+	return err
+}
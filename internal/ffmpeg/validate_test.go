@@ -0,0 +1,45 @@
+package ffmpeg
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseKeyframes(t *testing.T) {
+	tests := []struct {
+		name     string
+		output   string
+		expected []float64
+	}{
+		{
+			name:     "pts_time",
+			output:   "0.000000\n2.002000\n4.004000\n",
+			expected: []float64{0, 2.002, 4.004},
+		},
+		{
+			name:     "pkt_pts_time of ffprobe 4.x with best_effort_timestamp_time",
+			output:   "0.000000,0.000000\n2.002000,2.002000\n",
+			expected: []float64{0, 2.002},
+		},
+		{
+			name:     "empty pts_time falls back to the next field",
+			output:   ",0.000000\nN/A,2.002000\n",
+			expected: []float64{0, 2.002},
+		},
+		{
+			name:   "without timestamps",
+			output: ",\nN/A,N/A\n",
+		},
+		{
+			name: "empty output",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if keyframes := ParseKeyframes(tt.output); !reflect.DeepEqual(keyframes, tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, keyframes)
+			}
+		})
+	}
+}
@@ -0,0 +1,232 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+
+	"github.com/Voodfy/voodfy-transcoder/internal/settings"
+)
+
+// Job struct used to store the plan of a transcoding
+type Job struct {
+	ID        string                      `json:"id"`
+	Source    string                      `json:"source"`
+	Packaging string                      `json:"packaging"`
+	Ladder    []settings.RenditionProfile `json:"ladder"`
+	Audio     []settings.AudioProfile     `json:"audio"`
+	Streams   []AudioStream               `json:"streams"`
+	Subtitles []Subtitle                  `json:"subtitles"`
+	Poster    *Poster                     `json:"poster,omitempty"`
+	Keyframes []float64                   `json:"keyframes,omitempty"`
+	Chunks    []Chunk                     `json:"chunks,omitempty"`
+	Failures  []Failure                   `json:"failures,omitempty"`
+	Rejection string                      `json:"rejection,omitempty"`
+}
+
+// Chunk struct used to store a part of the source cut at its keyframes and transcoded
+// by its own task, Start and End are in seconds from the start of the source
+type Chunk struct {
+	Index int     `json:"index"`
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+}
+
+// Failure struct used to store the reason a rendition was rejected by the validation
+type Failure struct {
+	Rendition string `json:"rendition"`
+	Reason    string `json:"reason"`
+}
+
+// Poster struct used to store the frame of the source chosen as poster, Position is in seconds
+// and Override is true when it was given by the caller instead of selected
+type Poster struct {
+	Position   float64 `json:"position"`
+	Brightness float64 `json:"brightness"`
+	Sharpness  float64 `json:"sharpness"`
+	Override   bool    `json:"override"`
+}
+
+// Subtitle struct used to store a subtitle converted to webvtt, Index is the position among the
+// subtitle streams used by -map 0:s:<index> or -1 to a sidecar file uploaded with the source
+type Subtitle struct {
+	Index    int    `json:"index"`
+	Source   string `json:"source"`
+	Name     string `json:"name"`
+	File     string `json:"file"`
+	Language string `json:"language"`
+	Default  bool   `json:"default"`
+	Forced   bool   `json:"forced"`
+}
+
+// AudioStream struct used to store an audio stream of the source transcoded as its own track,
+// Index is the position among the audio streams used by -map 0:a:<index>
+type AudioStream struct {
+	Index       int       `json:"index"`
+	Language    string    `json:"language"`
+	Default     bool      `json:"default"`
+	Commentary  bool      `json:"commentary"`
+	Descriptive bool      `json:"descriptive"`
+	Loudness    *Loudness `json:"loudness,omitempty"`
+}
+
+// Loudness struct used to store the EBU R128 measurement of an audio stream,
+// Integrated and Threshold are in LUFS, TruePeak in dBTP, LRA in LU and Offset in LU
+type Loudness struct {
+	Integrated float64 `json:"integrated"`
+	TruePeak   float64 `json:"true_peak"`
+	LRA        float64 `json:"lra"`
+	Threshold  float64 `json:"threshold"`
+	Offset     float64 `json:"offset"`
+}
+
+// Profile return the rendition planned to the job with the name
+func (j *Job) Profile(name string) (settings.RenditionProfile, bool) {
+	for _, p := range j.Ladder {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return settings.RenditionProfile{}, false
+}
+
+// AudioProfile return the audio rendition planned to the job with the name
+func (j *Job) AudioProfile(name string) (settings.AudioProfile, bool) {
+	for _, p := range j.Audio {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return settings.AudioProfile{}, false
+}
+
+// AudioStream return the audio stream of the source with the index
+func (j *Job) AudioStream(index int) (AudioStream, bool) {
+	for _, s := range j.Streams {
+		if s.Index == index {
+			return s, true
+		}
+	}
+	return AudioStream{}, false
+}
+
+// SetFailure replace the failure of the rendition, the failure is removed when err is nil
+func (j *Job) SetFailure(rendition string, err error) {
+	var failures []Failure
+	for _, f := range j.Failures {
+		if f.Rendition != rendition {
+			failures = append(failures, f)
+		}
+	}
+
+	if err != nil {
+		failures = append(failures, Failure{Rendition: rendition, Reason: err.Error()})
+	}
+	j.Failures = failures
+}
+
+// Failed return true when a rendition of the job was rejected
+func (j *Job) Failed() bool {
+	return len(j.Failures) > 0
+}
+
+// MarshalBinary retrieve job from binary
+func (j *Job) MarshalBinary() ([]byte, error) {
+	return json.Marshal(j)
+}
+
+// UnmarshalBinary bind job save on redis
+func (j *Job) UnmarshalBinary(data []byte) error {
+	if err := json.Unmarshal(data, j); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Save add job to redis
+func (j *Job) Save() {
+	InitDB()
+
+	m, err := j.MarshalBinary()
+
+	if err != nil {
+		log.Println("err", err)
+	}
+
+	if err := db.Redis.Set(fmt.Sprintf("job_%s", j.ID), m, 0).Err(); err != nil {
+		fmt.Printf("Unable to store example struct into redis due to: %s \n", err)
+	}
+}
+
+// Get return a job save on redis
+func (j *Job) Get() {
+	InitDB()
+
+	cacheData, cacheErr := db.Redis.Get(fmt.Sprintf("job_%s", j.ID)).Result()
+
+	if cacheErr == nil {
+		if err := j.UnmarshalBinary([]byte(cacheData)); err != nil {
+			fmt.Printf("Unable to unmarshal data into the new example struct due to: %s \n", err)
+		}
+	}
+
+	failures, err := db.Redis.HGetAll(fmt.Sprintf("failures_%s", j.ID)).Result()
+	if err != nil {
+		return
+	}
+
+	renditions := make([]string, 0, len(failures))
+	for rendition := range failures {
+		renditions = append(renditions, rendition)
+	}
+	sort.Strings(renditions)
+
+	for _, rendition := range renditions {
+		if _, found := j.Failure(rendition); !found {
+			j.Failures = append(j.Failures, Failure{Rendition: rendition, Reason: failures[rendition]})
+		}
+	}
+}
+
+// Failure return the failure recorded to the rendition
+func (j *Job) Failure(rendition string) (Failure, bool) {
+	for _, f := range j.Failures {
+		if f.Rendition == rendition {
+			return f, true
+		}
+	}
+	return Failure{}, false
+}
+
+// AddFailure add the failure of the rendition to the hash of the job on redis, the tasks
+// running in parallel record their failures without saving the whole job over each other
+func (j *Job) AddFailure(rendition string, err error) {
+	InitDB()
+
+	if err := db.Redis.HSet(fmt.Sprintf("failures_%s", j.ID), rendition, err.Error()).Err(); err != nil {
+		fmt.Printf("Unable to store example struct into redis due to: %s \n", err)
+	}
+	j.SetFailure(rendition, err)
+}
+
+// Reset remove the failures and the chunk tasks counted to a previous run of the job
+func (j *Job) Reset() {
+	InitDB()
+
+	db.Redis.Del(fmt.Sprintf("failures_%s", j.ID), fmt.Sprintf("chunks_%s", j.ID))
+	j.Failures = nil
+}
+
+// FinishChunk count a chunk task finished, succeeded or failed, and return the number of
+// chunk tasks finished to the job
+func (j *Job) FinishChunk() int {
+	InitDB()
+
+	n, err := db.Redis.Incr(fmt.Sprintf("chunks_%s", j.ID)).Result()
+	if err != nil {
+		fmt.Printf("Unable to store example struct into redis due to: %s \n", err)
+	}
+	return int(n)
+}
@@ -0,0 +1,238 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// hdrTransfers transfer characteristics of the hdr10 and hlg sources
+var hdrTransfers = map[string]bool{
+	"smpte2084":    true,
+	"arib-std-b67": true,
+}
+
+// interlacedFields field orders of the interlaced sources
+var interlacedFields = map[string]bool{
+	"tt": true,
+	"bb": true,
+	"tb": true,
+	"bt": true,
+}
+
+// Rational struct used to keep the ratios reported by ffprobe like 30000/1001 or 16:9
+type Rational struct {
+	Num int `json:"num"`
+	Den int `json:"den"`
+}
+
+// ParseRational parse values like 30000/1001 or 16:9, ok is false to zero or invalid values
+func ParseRational(value string) (r Rational, ok bool) {
+	parts := strings.FieldsFunc(value, func(c rune) bool { return c == ':' || c == '/' })
+	if len(parts) != 2 {
+		return r, false
+	}
+
+	num, errNum := strconv.Atoi(parts[0])
+	den, errDen := strconv.Atoi(parts[1])
+	if errNum != nil || errDen != nil || num <= 0 || den <= 0 {
+		return r, false
+	}
+	return Rational{Num: num, Den: den}, true
+}
+
+// Float64 return the value of the ratio
+func (r Rational) Float64() float64 {
+	if r.Den == 0 {
+		return 0
+	}
+	return float64(r.Num) / float64(r.Den)
+}
+
+// String return the ratio as num/den
+func (r Rational) String() string {
+	return fmt.Sprintf("%d/%d", r.Num, r.Den)
+}
+
+// MediaInfo struct used to describe a media normalized from the specification
+type MediaInfo struct {
+	Filename   string         `json:"filename"`
+	FormatName string         `json:"format_name"`
+	Duration   time.Duration  `json:"duration"`
+	StartTime  time.Duration  `json:"start_time"`
+	BitRate    int            `json:"bit_rate"`
+	Size       int64          `json:"size"`
+	ProbeScore int            `json:"probe_score"`
+	Video      *VideoInfo     `json:"video,omitempty"`
+	Audios     []AudioInfo    `json:"audios"`
+	Subtitles  []SubtitleInfo `json:"subtitles"`
+}
+
+// VideoInfo struct used to describe the primary video stream, the display size applies
+// the sample aspect ratio and the rotation, the duration is zero when the container
+// doesn't report it to the stream
+type VideoInfo struct {
+	Index         int           `json:"index"`
+	Codec         string        `json:"codec"`
+	Profile       string        `json:"profile"`
+	Level         int           `json:"level"`
+	PixFmt        string        `json:"pix_fmt"`
+	BitRate       int           `json:"bit_rate"`
+	Width         int           `json:"width"`
+	Height        int           `json:"height"`
+	DisplayWidth  int           `json:"display_width"`
+	DisplayHeight int           `json:"display_height"`
+	FrameRate     Rational      `json:"frame_rate"`
+	Rotation      int           `json:"rotation"`
+	HDR           bool          `json:"hdr"`
+	Interlaced    bool          `json:"interlaced"`
+	Duration      time.Duration `json:"duration"`
+}
+
+// AudioInfo struct used to describe an audio stream, Index is the position among the audio
+// streams used by -map 0:a:<index> and StreamIndex the position among every stream
+type AudioInfo struct {
+	Index       int    `json:"index"`
+	StreamIndex int    `json:"stream_index"`
+	Codec       string `json:"codec"`
+	Profile     string `json:"profile"`
+	BitRate     int    `json:"bit_rate"`
+	Channels    int    `json:"channels"`
+	SampleRate  int    `json:"sample_rate"`
+	Language    string `json:"language"`
+	Default     bool   `json:"default"`
+	Commentary  bool   `json:"commentary"`
+	Descriptive bool   `json:"descriptive"`
+}
+
+// SubtitleInfo struct used to describe a subtitle stream, Index is the position among the
+// subtitle streams used by -map 0:s:<index> and StreamIndex the position among every stream
+type SubtitleInfo struct {
+	Index       int    `json:"index"`
+	StreamIndex int    `json:"stream_index"`
+	Codec       string `json:"codec"`
+	Language    string `json:"language"`
+	Default     bool   `json:"default"`
+	Forced      bool   `json:"forced"`
+}
+
+// MediaInfo return the specification normalized, the primary video is the first video stream
+// that isn't a cover art, an error is returned when the duration can't be parsed
+func (s *Specification) MediaInfo() (info MediaInfo, err error) {
+	info.Filename = s.Format.Filename
+	info.FormatName = s.Format.FormatName
+	info.BitRate, _ = strconv.Atoi(s.Format.BitRate)
+	info.Size, _ = strconv.ParseInt(s.Format.Size, 10, 64)
+	info.ProbeScore = s.Format.ProbeScore
+	if start, err := strconv.ParseFloat(s.Format.StartTime, 64); err == nil {
+		info.StartTime = time.Duration(start * float64(time.Second))
+	}
+
+	duration := s.Format.Duration
+	for _, st := range s.Streams {
+		bitRate, _ := strconv.Atoi(st.BitRate)
+
+		switch st.CodecType {
+		case "video":
+			if info.Video != nil || st.Disposition.AttachedPic == 1 || st.Width == 0 || st.Height == 0 {
+				continue
+			}
+
+			v := &VideoInfo{
+				Index:      st.Index,
+				Codec:      st.CodecName,
+				Profile:    st.Profile,
+				Level:      st.Level,
+				PixFmt:     st.PixFmt,
+				BitRate:    bitRate,
+				Width:      st.Width,
+				Height:     st.Height,
+				Rotation:   rotation(st.Tags.Rotate, st.SideDataList),
+				HDR:        hdrTransfers[st.ColorTransfer],
+				Interlaced: interlacedFields[st.FieldOrder],
+			}
+
+			var ok bool
+			if v.FrameRate, ok = ParseRational(st.AvgFrameRate); !ok {
+				v.FrameRate, _ = ParseRational(st.RFrameRate)
+			}
+
+			v.DisplayWidth, v.DisplayHeight = st.Width, st.Height
+			if sar, ok := ParseRational(st.SampleAspectRatio); ok && sar.Num != sar.Den {
+				v.DisplayWidth = int(math.Round(float64(st.Width) * sar.Float64()))
+			}
+			if v.Rotation == 90 || v.Rotation == 270 {
+				v.DisplayWidth, v.DisplayHeight = v.DisplayHeight, v.DisplayWidth
+			}
+
+			if d, err := strconv.ParseFloat(st.Duration, 64); err == nil {
+				v.Duration = time.Duration(d * float64(time.Second))
+			}
+			if duration == "" {
+				duration = st.Duration
+			}
+			info.Video = v
+		case "audio":
+			sampleRate, _ := strconv.Atoi(st.SampleRate)
+			info.Audios = append(info.Audios, AudioInfo{
+				Index:       len(info.Audios),
+				StreamIndex: st.Index,
+				Codec:       st.CodecName,
+				Profile:     st.Profile,
+				BitRate:     bitRate,
+				Channels:    st.Channels,
+				SampleRate:  sampleRate,
+				Language:    st.Tags.Language,
+				Default:     st.Disposition.Default == 1,
+				Commentary:  st.Disposition.Comment == 1,
+				Descriptive: st.Disposition.VisualImpaired == 1,
+			})
+		case "subtitle":
+			info.Subtitles = append(info.Subtitles, SubtitleInfo{
+				Index:       len(info.Subtitles),
+				StreamIndex: st.Index,
+				Codec:       st.CodecName,
+				Language:    st.Tags.Language,
+				Default:     st.Disposition.Default == 1,
+				Forced:      st.Disposition.Forced == 1,
+			})
+		}
+	}
+
+	d, err := strconv.ParseFloat(duration, 64)
+	if err != nil {
+		return info, fmt.Errorf("models: invalid duration %q of %s: %w", duration, s.Format.Filename, err)
+	}
+	info.Duration = time.Duration(d * float64(time.Second))
+
+	return info, nil
+}
+
+// VideoDuration return the duration of the video stream or the duration of the media when the
+// stream doesn't report it, the media duration also counts the audio streams
+func (m MediaInfo) VideoDuration() time.Duration {
+	if m.Video != nil && m.Video.Duration > 0 {
+		return m.Video.Duration
+	}
+	return m.Duration
+}
+
+// rotation return the clockwise rotation between 0 and 270 from the rotate tag or the display matrix
+func rotation(tag string, sideData []struct {
+	SideDataType string `json:"side_data_type"`
+	Rotation     int    `json:"rotation"`
+}) int {
+	r, err := strconv.Atoi(tag)
+	if err != nil {
+		for _, d := range sideData {
+			if d.SideDataType == "Display Matrix" {
+				// the display matrix is counterclockwise
+				r = -d.Rotation
+			}
+		}
+	}
+
+	return ((r % 360) + 360) % 360
+}
@@ -0,0 +1,80 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+)
+
+// progressTTL time that the progress of a job is kept on redis
+const progressTTL = 24 * time.Hour
+
+// Progress struct used to store the progress of a task reported by ffmpeg
+type Progress struct {
+	ID      string  `json:"id"`
+	Task    string  `json:"task"`
+	Percent float64 `json:"percent"`
+	OutTime float64 `json:"outTime"`
+	FPS     float64 `json:"fps"`
+	Speed   string  `json:"speed"`
+	Done    bool    `json:"done"`
+}
+
+// MarshalBinary retrieve progress from binary
+func (p *Progress) MarshalBinary() ([]byte, error) {
+	return json.Marshal(p)
+}
+
+// UnmarshalBinary bind progress save on redis
+func (p *Progress) UnmarshalBinary(data []byte) error {
+	if err := json.Unmarshal(data, p); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Save add the progress of the task to the hash of the job on redis
+func (p *Progress) Save() {
+	if db == nil {
+		InitDB()
+	}
+
+	m, err := p.MarshalBinary()
+
+	if err != nil {
+		log.Println("err", err)
+	}
+
+	key := fmt.Sprintf("progress_%s", p.ID)
+	if err := db.Redis.HSet(key, p.Task, m).Err(); err != nil {
+		fmt.Printf("Unable to store example struct into redis due to: %s \n", err)
+	}
+	db.Redis.Expire(key, progressTTL)
+}
+
+// GetProgress return the progress of every task of the job save on redis
+func GetProgress(id string) (progress []Progress) {
+	InitDB()
+
+	cacheData, cacheErr := db.Redis.HGetAll(fmt.Sprintf("progress_%s", id)).Result()
+
+	if cacheErr != nil {
+		return progress
+	}
+
+	for _, data := range cacheData {
+		var p Progress
+		if err := p.UnmarshalBinary([]byte(data)); err != nil {
+			fmt.Printf("Unable to unmarshal data into the new example struct due to: %s \n", err)
+			continue
+		}
+		progress = append(progress, p)
+	}
+
+	sort.Slice(progress, func(i, j int) bool { return progress[i].Task < progress[j].Task })
+
+	return progress
+}
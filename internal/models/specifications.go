@@ -75,15 +75,23 @@ type Specification struct {
 		Tags              struct {
 			HandlerName string `json:"handler_name"`
 			Language    string `json:"language"`
+			Rotate      string `json:"rotate,omitempty"`
 		} `json:"tags"`
-		TimeBase      string `json:"time_base"`
-		Width         int    `json:"width,omitempty"`
-		BitsPerSample int    `json:"bits_per_sample,omitempty"`
-		ChannelLayout string `json:"channel_layout,omitempty"`
-		Channels      int    `json:"channels,omitempty"`
-		MaxBitRate    string `json:"max_bit_rate,omitempty"`
-		SampleFmt     string `json:"sample_fmt,omitempty"`
-		SampleRate    string `json:"sample_rate,omitempty"`
+		TimeBase       string `json:"time_base"`
+		Width          int    `json:"width,omitempty"`
+		BitsPerSample  int    `json:"bits_per_sample,omitempty"`
+		ChannelLayout  string `json:"channel_layout,omitempty"`
+		Channels       int    `json:"channels,omitempty"`
+		MaxBitRate     string `json:"max_bit_rate,omitempty"`
+		SampleFmt      string `json:"sample_fmt,omitempty"`
+		SampleRate     string `json:"sample_rate,omitempty"`
+		FieldOrder     string `json:"field_order,omitempty"`
+		ColorTransfer  string `json:"color_transfer,omitempty"`
+		ColorPrimaries string `json:"color_primaries,omitempty"`
+		SideDataList   []struct {
+			SideDataType string `json:"side_data_type"`
+			Rotation     int    `json:"rotation"`
+		} `json:"side_data_list,omitempty"`
 	} `json:"streams"`
 }
 
@@ -0,0 +1,148 @@
+package mp4
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ErrInvalidBox returned when a box header doesn't fit on its parent
+var ErrInvalidBox = errors.New("mp4: invalid box")
+
+// containers boxes that only hold other boxes
+var containers = map[string]bool{
+	"moov": true,
+	"trak": true,
+	"mdia": true,
+	"minf": true,
+	"stbl": true,
+	"edts": true,
+	"mvex": true,
+	"moof": true,
+	"traf": true,
+}
+
+// payloads boxes whose content is read, the others like mdat are skipped
+var payloads = map[string]bool{
+	"ftyp": true,
+	"mvhd": true,
+	"mehd": true,
+	"tkhd": true,
+	"mdhd": true,
+	"hdlr": true,
+	"stsd": true,
+	"stts": true,
+	"stss": true,
+	"stsz": true,
+	"sidx": true,
+}
+
+// Box struct used to describe a box of the file, the payload is only kept to the boxes
+// parsed by the inspector
+type Box struct {
+	Type     string
+	Offset   int64
+	Size     int64
+	Header   int64
+	Payload  []byte
+	Children []*Box
+}
+
+// Find return the first descendant on the path of types like moov/trak
+func (b *Box) Find(path ...string) *Box {
+	return find(b.Children, path...)
+}
+
+// FindAll return the children of the type
+func (b *Box) FindAll(kind string) []*Box {
+	var boxes []*Box
+	for _, c := range b.Children {
+		if c.Type == kind {
+			boxes = append(boxes, c)
+		}
+	}
+	return boxes
+}
+
+// find return the first box on the path of types
+func find(boxes []*Box, path ...string) *Box {
+	for _, b := range boxes {
+		if b.Type != path[0] {
+			continue
+		}
+		if len(path) == 1 {
+			return b
+		}
+		if found := find(b.Children, path[1:]...); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// ReadBoxes read the box tree between the offset and the end, the containers are walked
+// and the payload of the known boxes is loaded
+func ReadBoxes(r io.ReadSeeker, offset, end int64) ([]*Box, error) {
+	var boxes []*Box
+
+	for offset+8 <= end {
+		b, err := readHeader(r, offset, end)
+		if err != nil {
+			return boxes, err
+		}
+
+		switch {
+		case containers[b.Type]:
+			if b.Children, err = ReadBoxes(r, b.Offset+b.Header, b.Offset+b.Size); err != nil {
+				return boxes, err
+			}
+		case payloads[b.Type]:
+			b.Payload = make([]byte, b.Size-b.Header)
+			if _, err := io.ReadFull(r, b.Payload); err != nil {
+				return boxes, fmt.Errorf("%w: %s at %d: %v", ErrInvalidBox, b.Type, b.Offset, err)
+			}
+		}
+
+		boxes = append(boxes, b)
+		offset += b.Size
+	}
+
+	return boxes, nil
+}
+
+// readHeader read the size and the type of the box at the offset, a size 1 is followed by
+// the 64 bits size and a size 0 extends the box to the end
+func readHeader(r io.ReadSeeker, offset, end int64) (*Box, error) {
+	if _, err := r.Seek(offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, 16)
+	if _, err := io.ReadFull(r, header[:8]); err != nil {
+		return nil, fmt.Errorf("%w: header at %d: %v", ErrInvalidBox, offset, err)
+	}
+
+	b := &Box{
+		Type:   string(header[4:8]),
+		Offset: offset,
+		Size:   int64(binary.BigEndian.Uint32(header)),
+		Header: 8,
+	}
+
+	switch b.Size {
+	case 0:
+		b.Size = end - offset
+	case 1:
+		if _, err := io.ReadFull(r, header[8:]); err != nil {
+			return nil, fmt.Errorf("%w: %s size at %d: %v", ErrInvalidBox, b.Type, offset, err)
+		}
+		b.Size = int64(binary.BigEndian.Uint64(header[8:]))
+		b.Header = 16
+	}
+
+	if b.Size < b.Header || offset+b.Size > end {
+		return nil, fmt.Errorf("%w: %s at %d with size %d", ErrInvalidBox, b.Type, offset, b.Size)
+	}
+	return b, nil
+}
@@ -0,0 +1,161 @@
+package mp4
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+)
+
+// box return the box of the type with the 32 bits size
+func box(kind string, payload ...[]byte) []byte {
+	content := bytes.Join(payload, nil)
+	b := make([]byte, 8, 8+len(content))
+	binary.BigEndian.PutUint32(b, uint32(8+len(content)))
+	copy(b[4:], kind)
+	return append(b, content...)
+}
+
+// largeBox return the box of the type with the size 1 followed by the 64 bits size
+func largeBox(kind string, payload ...[]byte) []byte {
+	content := bytes.Join(payload, nil)
+	b := make([]byte, 16, 16+len(content))
+	binary.BigEndian.PutUint32(b, 1)
+	copy(b[4:], kind)
+	binary.BigEndian.PutUint64(b[8:], uint64(16+len(content)))
+	return append(b, content...)
+}
+
+// openBox return the box of the type with the size 0 that extends it to the end
+func openBox(kind string, payload ...[]byte) []byte {
+	b := box(kind, payload...)
+	binary.BigEndian.PutUint32(b, 0)
+	return b
+}
+
+// u32 return the values as big endian 32 bits
+func u32(values ...uint32) []byte {
+	b := make([]byte, 4*len(values))
+	for idx, v := range values {
+		binary.BigEndian.PutUint32(b[4*idx:], v)
+	}
+	return b
+}
+
+// u64 return the value as big endian 64 bits
+func u64(v uint64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, v)
+	return b
+}
+
+func TestReadBoxes(t *testing.T) {
+	type expected struct {
+		kind   string
+		offset int64
+		size   int64
+		header int64
+	}
+
+	tests := []struct {
+		name     string
+		data     []byte
+		expected []expected
+		children []string
+		err      error
+	}{
+		{
+			name: "32 bits sizes",
+			data: bytes.Join([][]byte{box("ftyp", []byte("isom"), u32(512)), box("free", make([]byte, 4))}, nil),
+			expected: []expected{
+				{"ftyp", 0, 16, 8},
+				{"free", 16, 12, 8},
+			},
+		},
+		{
+			name: "64 bits size",
+			data: bytes.Join([][]byte{box("ftyp", []byte("isom"), u32(512)), largeBox("mdat", make([]byte, 10)), box("free")}, nil),
+			expected: []expected{
+				{"ftyp", 0, 16, 8},
+				{"mdat", 16, 26, 16},
+				{"free", 42, 8, 8},
+			},
+		},
+		{
+			name: "size 0 extends to the end",
+			data: bytes.Join([][]byte{box("ftyp", []byte("isom"), u32(512)), openBox("mdat", make([]byte, 20))}, nil),
+			expected: []expected{
+				{"ftyp", 0, 16, 8},
+				{"mdat", 16, 28, 8},
+			},
+		},
+		{
+			name: "containers are walked",
+			data: box("moov", box("mvhd", make([]byte, 20)), box("trak", box("tkhd", make([]byte, 4)))),
+			expected: []expected{
+				{"moov", 0, 56, 8},
+			},
+			children: []string{"mvhd", "trak"},
+		},
+		{
+			name: "size beyond the end",
+			data: append(u32(64), []byte("free")...),
+			err:  ErrInvalidBox,
+		},
+		{
+			name: "size smaller than the header",
+			data: append(u32(4), []byte("free")...),
+			err:  ErrInvalidBox,
+		},
+		{
+			name: "64 bits size truncated",
+			data: append(u32(1), []byte("mdat")...),
+			err:  ErrInvalidBox,
+		},
+		{
+			name: "child beyond its parent",
+			data: box("moov", append(u32(32), []byte("trak")...)),
+			err:  ErrInvalidBox,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			boxes, err := ReadBoxes(bytes.NewReader(tt.data), 0, int64(len(tt.data)))
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("expected %v, got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(boxes) != len(tt.expected) {
+				t.Fatalf("expected %d boxes, got %d", len(tt.expected), len(boxes))
+			}
+			for idx, e := range tt.expected {
+				b := boxes[idx]
+				if b.Type != e.kind || b.Offset != e.offset || b.Size != e.size || b.Header != e.header {
+					t.Errorf("box %d: expected %+v, got %s at %d with size %d and header %d", idx, e, b.Type, b.Offset, b.Size, b.Header)
+				}
+			}
+
+			if tt.children != nil {
+				var children []string
+				for _, c := range boxes[0].Children {
+					children = append(children, c.Type)
+				}
+				if len(children) != len(tt.children) {
+					t.Fatalf("expected children %v, got %v", tt.children, children)
+				}
+				for idx := range children {
+					if children[idx] != tt.children[idx] {
+						t.Fatalf("expected children %v, got %v", tt.children, children)
+					}
+				}
+			}
+		})
+	}
+}
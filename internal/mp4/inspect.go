@@ -0,0 +1,348 @@
+package mp4
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// Info struct used to describe the file from its box tree
+type Info struct {
+	MajorBrand       string
+	CompatibleBrands []string
+	Duration         time.Duration
+	FastStart        bool
+	Fragmented       bool
+	Fragments        int
+	Segments         int
+	Tracks           []Track
+}
+
+// Track struct used to describe a track, the keyframes are the decode timestamps of the
+// sync samples and are empty when every sample is a keyframe or the track is fragmented
+type Track struct {
+	ID        uint32
+	Handler   string
+	Codec     string
+	Timescale uint32
+	Duration  time.Duration
+	Width     int
+	Height    int
+	Samples   int
+	Keyframes []time.Duration
+}
+
+// Video return the first video track
+func (i Info) Video() (Track, bool) {
+	return i.track("vide")
+}
+
+// Audio return the first audio track
+func (i Info) Audio() (Track, bool) {
+	return i.track("soun")
+}
+
+// track return the first track of the handler
+func (i Info) track(handler string) (Track, bool) {
+	for _, t := range i.Tracks {
+		if t.Handler == handler {
+			return t, true
+		}
+	}
+	return Track{}, false
+}
+
+// InspectFile open the file and inspect it
+func InspectFile(filename string) (Info, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return Info{}, err
+	}
+	defer f.Close()
+
+	return Inspect(f)
+}
+
+// Inspect read the box tree and return the brands, the duration, the tracks and whether
+// the moov is written before the first mdat
+func Inspect(r io.ReadSeeker) (Info, error) {
+	var info Info
+
+	end, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return info, err
+	}
+
+	boxes, err := ReadBoxes(r, 0, end)
+	if err != nil {
+		return info, err
+	}
+
+	if ftyp := find(boxes, "ftyp"); ftyp != nil {
+		p := parser{b: ftyp.Payload}
+		info.MajorBrand = p.fourcc()
+		p.skip(4)
+		for len(p.b) >= 4 {
+			info.CompatibleBrands = append(info.CompatibleBrands, p.fourcc())
+		}
+	}
+
+	moov := find(boxes, "moov")
+	if moov == nil {
+		return info, errors.New("mp4: without moov")
+	}
+
+	var moovAt, mdatAt int64 = -1, -1
+	for _, b := range boxes {
+		switch b.Type {
+		case "moov":
+			moovAt = b.Offset
+		case "mdat":
+			if mdatAt < 0 {
+				mdatAt = b.Offset
+			}
+		case "moof":
+			info.Fragments++
+		case "sidx":
+			info.Segments += segments(b)
+		}
+	}
+	info.FastStart = mdatAt < 0 || moovAt < mdatAt
+
+	return info.parse(moov)
+}
+
+// parse read the movie header and the tracks of the moov
+func (i Info) parse(moov *Box) (Info, error) {
+	i.Fragmented = moov.Find("mvex") != nil
+
+	var timescale uint32
+	if mvhd := moov.Find("mvhd"); mvhd != nil {
+		var duration uint64
+		var err error
+		if timescale, duration, err = mediaHeader(mvhd.Payload); err != nil {
+			return i, fmt.Errorf("mp4: mvhd: %w", err)
+		}
+		i.Duration = toDuration(duration, timescale)
+	}
+
+	// the fragmented files keep the whole duration on the movie extends header
+	if mehd := moov.Find("mvex", "mehd"); i.Duration == 0 && mehd != nil {
+		p := parser{b: mehd.Payload}
+		var duration uint64
+		if p.u8() == 1 {
+			p.skip(3)
+			duration = p.u64()
+		} else {
+			p.skip(3)
+			duration = uint64(p.u32())
+		}
+		i.Duration = toDuration(duration, timescale)
+	}
+
+	for _, trak := range moov.FindAll("trak") {
+		t, err := parseTrack(trak)
+		if err != nil {
+			return i, err
+		}
+		i.Tracks = append(i.Tracks, t)
+	}
+
+	return i, nil
+}
+
+// parseTrack read the header, the handler, the sample description and the sync samples of the trak
+func parseTrack(trak *Box) (t Track, err error) {
+	if tkhd := trak.Find("tkhd"); tkhd != nil {
+		p := parser{b: tkhd.Payload}
+		if p.u8() == 1 {
+			p.skip(3 + 16)
+			t.ID = p.u32()
+			p.skip(4 + 8)
+		} else {
+			p.skip(3 + 8)
+			t.ID = p.u32()
+			p.skip(4 + 4)
+		}
+		// reserved, layer, alternate group, volume, reserved and the matrix
+		p.skip(8 + 8 + 36)
+		t.Width = int(p.u32() >> 16)
+		t.Height = int(p.u32() >> 16)
+		if p.err != nil {
+			return t, fmt.Errorf("mp4: tkhd: %w", p.err)
+		}
+	}
+
+	if mdhd := trak.Find("mdia", "mdhd"); mdhd != nil {
+		timescale, duration, err := mediaHeader(mdhd.Payload)
+		if err != nil {
+			return t, fmt.Errorf("mp4: mdhd of track %d: %w", t.ID, err)
+		}
+		t.Timescale = timescale
+		t.Duration = toDuration(duration, timescale)
+	}
+
+	if hdlr := trak.Find("mdia", "hdlr"); hdlr != nil {
+		p := parser{b: hdlr.Payload}
+		p.skip(8)
+		t.Handler = p.fourcc()
+	}
+
+	stbl := trak.Find("mdia", "minf", "stbl")
+	if stbl == nil {
+		return t, nil
+	}
+
+	if stsd := stbl.Find("stsd"); stsd != nil {
+		p := parser{b: stsd.Payload}
+		p.skip(8 + 4)
+		t.Codec = p.fourcc()
+	}
+
+	if stsz := stbl.Find("stsz"); stsz != nil {
+		p := parser{b: stsz.Payload}
+		p.skip(8)
+		t.Samples = int(p.u32())
+	}
+
+	if stss := stbl.Find("stss"); stss != nil {
+		t.Keyframes, err = keyframes(stss.Payload, stbl.Find("stts"), t.Timescale)
+		if err != nil {
+			return t, fmt.Errorf("mp4: stss of track %d: %w", t.ID, err)
+		}
+	}
+
+	return t, nil
+}
+
+// keyframes return the decode timestamp of each sync sample using the time to sample table
+func keyframes(stss []byte, stts *Box, timescale uint32) ([]time.Duration, error) {
+	p := parser{b: stss}
+	p.skip(4)
+	count := p.u32()
+
+	var deltas parser
+	if stts != nil {
+		deltas = parser{b: stts.Payload}
+		deltas.skip(8)
+	}
+
+	var sample, remaining uint32 = 1, 0
+	var delta, decode uint64
+
+	keyframes := make([]time.Duration, 0, count)
+	for i := uint32(0); i < count && p.err == nil; i++ {
+		sync := p.u32()
+		for sample < sync && deltas.err == nil {
+			if remaining == 0 {
+				remaining, delta = deltas.u32(), uint64(deltas.u32())
+				continue
+			}
+			decode += delta
+			remaining--
+			sample++
+		}
+		keyframes = append(keyframes, toDuration(decode, timescale))
+	}
+
+	if p.err != nil {
+		return nil, p.err
+	}
+	return keyframes, nil
+}
+
+// segments return the number of references of the segment index
+func segments(sidx *Box) int {
+	p := parser{b: sidx.Payload}
+	version := p.u8()
+	p.skip(3 + 4 + 4)
+	if version == 1 {
+		p.skip(16)
+	} else {
+		p.skip(8)
+	}
+	p.skip(2)
+	return int(p.u16())
+}
+
+// mediaHeader return the timescale and the duration of the mvhd and mdhd boxes, the version 1
+// uses 64 bits to the dates and the duration
+func mediaHeader(payload []byte) (uint32, uint64, error) {
+	p := parser{b: payload}
+	if p.u8() == 1 {
+		p.skip(3 + 16)
+		timescale := p.u32()
+		return timescale, p.u64(), p.err
+	}
+
+	p.skip(3 + 8)
+	timescale := p.u32()
+	return timescale, uint64(p.u32()), p.err
+}
+
+// toDuration convert the value on the timescale to a duration
+func toDuration(value uint64, timescale uint32) time.Duration {
+	if timescale == 0 {
+		return 0
+	}
+	return time.Duration(float64(value) / float64(timescale) * float64(time.Second))
+}
+
+// parser read big endian values keeping the first error, the reads after it return zero
+type parser struct {
+	b   []byte
+	err error
+}
+
+// next return the next n bytes
+func (p *parser) next(n int) []byte {
+	if p.err != nil {
+		return nil
+	}
+	if len(p.b) < n {
+		p.err = io.ErrUnexpectedEOF
+		return nil
+	}
+	v := p.b[:n]
+	p.b = p.b[n:]
+	return v
+}
+
+func (p *parser) skip(n int) {
+	p.next(n)
+}
+
+func (p *parser) u8() uint8 {
+	if b := p.next(1); b != nil {
+		return b[0]
+	}
+	return 0
+}
+
+func (p *parser) u16() uint16 {
+	if b := p.next(2); b != nil {
+		return binary.BigEndian.Uint16(b)
+	}
+	return 0
+}
+
+func (p *parser) u32() uint32 {
+	if b := p.next(4); b != nil {
+		return binary.BigEndian.Uint32(b)
+	}
+	return 0
+}
+
+func (p *parser) u64() uint64 {
+	if b := p.next(8); b != nil {
+		return binary.BigEndian.Uint64(b)
+	}
+	return 0
+}
+
+func (p *parser) fourcc() string {
+	return string(p.next(4))
+}
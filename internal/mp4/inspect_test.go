@@ -0,0 +1,237 @@
+package mp4
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// ftyp return the file type box with the isom brand
+func ftyp() []byte {
+	return box("ftyp", []byte("isom"), u32(512), []byte("isomavc1"))
+}
+
+// mvhd return the movie header of the version 0
+func mvhd(timescale, duration uint32) []byte {
+	return box("mvhd", u32(0, 0, 0, timescale, duration))
+}
+
+// trak return a video track of 1280x720 with the sample tables
+func trak(stbl ...[]byte) []byte {
+	tkhd := box("tkhd", u32(0, 0, 0, 1, 0, 0), make([]byte, 8+8+36), u32(1280<<16, 720<<16))
+	mdhd := box("mdhd", u32(0, 0, 0, 90000, 900000))
+	hdlr := box("hdlr", u32(0, 0), []byte("vide"), make([]byte, 12))
+	stsd := box("stsd", u32(0, 1), box("avc1", make([]byte, 8)))
+
+	return box("trak", tkhd, box("mdia", mdhd, hdlr, box("minf", box("stbl", append([][]byte{stsd}, stbl...)...))))
+}
+
+// sidx return the segment index of the version with the number of references
+func sidx(version uint8, references uint16) []byte {
+	header := u32(uint32(version)<<24, 1, 90000)
+	if version == 1 {
+		header = append(header, make([]byte, 16)...)
+	} else {
+		header = append(header, make([]byte, 8)...)
+	}
+	header = append(header, 0, 0, byte(references>>8), byte(references))
+	return box("sidx", header, make([]byte, 12*int(references)))
+}
+
+func TestInspect(t *testing.T) {
+	stts := box("stts", u32(0, 2, 5, 3000, 10, 6000))
+	stss := box("stss", u32(0, 3, 1, 4, 8))
+	stsz := box("stsz", u32(0, 0, 15))
+	moov := box("moov", mvhd(1000, 10000), trak(stts, stss, stsz))
+	mdat := box("mdat", make([]byte, 32))
+
+	fragmented := box("moov", mvhd(1000, 0), box("mvex", box("mehd", u32(0, 12000))), trak())
+
+	tests := []struct {
+		name     string
+		data     []byte
+		expected Info
+		err      error
+	}{
+		{
+			name: "faststart",
+			data: bytes.Join([][]byte{ftyp(), moov, mdat}, nil),
+			expected: Info{
+				MajorBrand:       "isom",
+				CompatibleBrands: []string{"isom", "avc1"},
+				Duration:         10 * time.Second,
+				FastStart:        true,
+			},
+		},
+		{
+			name: "moov after the mdat",
+			data: bytes.Join([][]byte{ftyp(), mdat, moov}, nil),
+			expected: Info{
+				MajorBrand:       "isom",
+				CompatibleBrands: []string{"isom", "avc1"},
+				Duration:         10 * time.Second,
+			},
+		},
+		{
+			name: "64 bits mdat before the moov",
+			data: bytes.Join([][]byte{ftyp(), largeBox("mdat", make([]byte, 32)), moov}, nil),
+			expected: Info{
+				MajorBrand:       "isom",
+				CompatibleBrands: []string{"isom", "avc1"},
+				Duration:         10 * time.Second,
+			},
+		},
+		{
+			name: "mdat extended to the end",
+			data: bytes.Join([][]byte{ftyp(), moov, openBox("mdat", make([]byte, 32))}, nil),
+			expected: Info{
+				MajorBrand:       "isom",
+				CompatibleBrands: []string{"isom", "avc1"},
+				Duration:         10 * time.Second,
+				FastStart:        true,
+			},
+		},
+		{
+			name: "fragmented with segment indexes",
+			data: bytes.Join([][]byte{ftyp(), fragmented, sidx(0, 3), sidx(1, 2), box("moof"), mdat, box("moof"), mdat}, nil),
+			expected: Info{
+				MajorBrand:       "isom",
+				CompatibleBrands: []string{"isom", "avc1"},
+				Duration:         12 * time.Second,
+				FastStart:        true,
+				Fragmented:       true,
+				Fragments:        2,
+				Segments:         5,
+			},
+		},
+		{
+			name: "without moov",
+			data: bytes.Join([][]byte{ftyp(), mdat}, nil),
+			err:  errors.New("mp4: without moov"),
+		},
+		{
+			name: "truncated moov",
+			data: bytes.Join([][]byte{ftyp(), moov[:len(moov)-4]}, nil),
+			err:  ErrInvalidBox,
+		},
+		{
+			name: "truncated mdat",
+			data: bytes.Join([][]byte{ftyp(), moov, mdat[:16]}, nil),
+			err:  ErrInvalidBox,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info, err := Inspect(bytes.NewReader(tt.data))
+			if tt.err != nil {
+				if err == nil || (!errors.Is(err, tt.err) && err.Error() != tt.err.Error()) {
+					t.Fatalf("expected %v, got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			info.Tracks = nil
+			if !reflect.DeepEqual(info, tt.expected) {
+				t.Errorf("expected %+v, got %+v", tt.expected, info)
+			}
+		})
+	}
+}
+
+func TestInspectTrack(t *testing.T) {
+	tests := []struct {
+		name      string
+		stbl      [][]byte
+		samples   int
+		keyframes []time.Duration
+	}{
+		{
+			name: "keyframes from stss and stts",
+			stbl: [][]byte{
+				box("stts", u32(0, 2, 5, 3000, 10, 6000)),
+				box("stss", u32(0, 3, 1, 4, 8)),
+				box("stsz", u32(0, 0, 15)),
+			},
+			samples:   15,
+			keyframes: []time.Duration{0, 100 * time.Millisecond, 300 * time.Millisecond},
+		},
+		{
+			name: "keyframe on the last sample of an entry",
+			stbl: [][]byte{
+				box("stts", u32(0, 1, 10, 3000)),
+				box("stss", u32(0, 2, 1, 10)),
+				box("stsz", u32(0, 0, 10)),
+			},
+			samples:   10,
+			keyframes: []time.Duration{0, 300 * time.Millisecond},
+		},
+		{
+			name: "every sample is a keyframe without stss",
+			stbl: [][]byte{
+				box("stts", u32(0, 1, 10, 3000)),
+				box("stsz", u32(0, 0, 10)),
+			},
+			samples: 10,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := bytes.Join([][]byte{ftyp(), box("moov", mvhd(1000, 10000), trak(tt.stbl...))}, nil)
+			info, err := Inspect(bytes.NewReader(data))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			video, ok := info.Video()
+			if !ok {
+				t.Fatal("expected a video track")
+			}
+
+			expected := Track{
+				ID:        1,
+				Handler:   "vide",
+				Codec:     "avc1",
+				Timescale: 90000,
+				Duration:  10 * time.Second,
+				Width:     1280,
+				Height:    720,
+				Samples:   tt.samples,
+				Keyframes: tt.keyframes,
+			}
+			if !reflect.DeepEqual(video, expected) {
+				t.Errorf("expected %+v, got %+v", expected, video)
+			}
+		})
+	}
+}
+
+func TestSegments(t *testing.T) {
+	tests := []struct {
+		name       string
+		sidx       []byte
+		references int
+	}{
+		{"version 0", sidx(0, 4), 4},
+		{"version 1", sidx(1, 7), 7},
+		{"without references", sidx(0, 0), 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			boxes, err := ReadBoxes(bytes.NewReader(tt.sidx), 0, int64(len(tt.sidx)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n := segments(boxes[0]); n != tt.references {
+				t.Errorf("expected %d references, got %d", tt.references, n)
+			}
+		})
+	}
+}
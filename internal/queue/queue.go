@@ -39,6 +39,7 @@ func startServer() (*machinery.Server, error) {
 			"%s", settings.RedisSetting.TranscoderResultURL),
 	}
 	server, _ := machinery.NewServer(cnf)
+	task.SetServer(server)
 
 	// Register tasks
 	tasks := task.Get()
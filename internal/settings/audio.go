@@ -0,0 +1,88 @@
+package settings
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+// AudioProfile struct used to bind a rendition of the audio ladder
+type AudioProfile struct {
+	Name       string `ini:"-"`
+	Codec      string
+	Bitrate    string
+	Channels   int
+	SampleRate int
+}
+
+// AudioLadder struct used to bind the audio renditions transcoded by the local chain
+type AudioLadder struct {
+	Renditions []string
+	Profiles   []AudioProfile `ini:"-"`
+}
+
+// AudioLadderSetting instance from audio, the defaults are used when
+// the app.ini doesn't declare any audio rendition
+var AudioLadderSetting = &AudioLadder{
+	Renditions: []string{"aac_64k", "aac_128k", "opus_96k"},
+	Profiles: []AudioProfile{
+		{Name: "aac_64k", Codec: "aac", Bitrate: "64k", Channels: 2, SampleRate: 48000},
+		{Name: "aac_128k", Codec: "aac", Bitrate: "128k", Channels: 2, SampleRate: 48000},
+		{Name: "opus_96k", Codec: "libopus", Bitrate: "96k", Channels: 2, SampleRate: 48000},
+	},
+}
+
+// Profile return the audio profile registered with the name
+func (l *AudioLadder) Profile(name string) (AudioProfile, bool) {
+	for _, p := range l.Profiles {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return AudioProfile{}, false
+}
+
+// Enabled return the profiles listed on renditions keeping the order
+func (l *AudioLadder) Enabled() []AudioProfile {
+	var profiles []AudioProfile
+	for _, name := range l.Renditions {
+		p, ok := l.Profile(strings.TrimSpace(name))
+		if !ok {
+			log.Printf("settings.AudioLadder, rendition '%s' without profile", name)
+			continue
+		}
+		profiles = append(profiles, p)
+	}
+	return profiles
+}
+
+// loadAudioLadder bind the sections [audio.<name>] over the default profiles
+func loadAudioLadder() error {
+	if err := mapTo("audio", AudioLadderSetting); err != nil {
+		return err
+	}
+
+	for _, section := range cfg.Section("audio").ChildSections() {
+		name := strings.TrimPrefix(section.Name(), "audio.")
+		p, _ := AudioLadderSetting.Profile(name)
+
+		if err := section.MapTo(&p); err != nil {
+			return fmt.Errorf("Cfg.MapTo %s err: %v", section.Name(), err)
+		}
+		p.Name = name
+
+		AudioLadderSetting.set(p)
+	}
+	return nil
+}
+
+// set replace or append the profile
+func (l *AudioLadder) set(profile AudioProfile) {
+	for idx, p := range l.Profiles {
+		if p.Name == profile.Name {
+			l.Profiles[idx] = profile
+			return
+		}
+	}
+	l.Profiles = append(l.Profiles, profile)
+}
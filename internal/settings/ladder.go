@@ -0,0 +1,175 @@
+package settings
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+)
+
+const (
+	// RateControlCRF constant quality without limit of bitrate
+	RateControlCRF = "crf"
+	// RateControlCappedCRF constant quality limited by the maxrate, or the bitrate when it isn't declared
+	RateControlCappedCRF = "capped_crf"
+	// RateControlTwoPass average bitrate reached by an analysis pass followed by the encode
+	RateControlTwoPass = "2pass"
+)
+
+// RenditionProfile struct used to bind a rendition of the ladder
+type RenditionProfile struct {
+	Name          string `ini:"-"`
+	Width         int
+	Height        int
+	Codec         string
+	Profile       string
+	RateControl   string
+	CRF           int
+	Bitrate       string
+	Maxrate       string
+	Bufsize       string
+	GOP           int
+	Container     string
+	TimeoutFactor float64
+}
+
+// Extension return the container of the rendition, mp4 when it isn't declared
+func (p RenditionProfile) Extension() string {
+	if p.Container == "" {
+		return "mp4"
+	}
+	return p.Container
+}
+
+// Mode return the rate control of the rendition, the profiles that don't declare it use capped
+// crf when they have a crf and a bitrate and crf when they only have a crf, it is empty when
+// the profile has neither
+func (p RenditionProfile) Mode() string {
+	if p.RateControl != "" {
+		return p.RateControl
+	}
+
+	switch {
+	case p.CRF > 0 && (p.Maxrate != "" || p.Bitrate != ""):
+		return RateControlCappedCRF
+	case p.CRF > 0:
+		return RateControlCRF
+	}
+	return ""
+}
+
+// check return the reason the rate control of the profile can't be encoded, libsvtav1
+// doesn't take the -pass and -passlogfile of the two-pass encode
+func (p RenditionProfile) check() error {
+	switch p.Mode() {
+	case RateControlCRF:
+		if p.CRF <= 0 {
+			return errors.New("crf without CRF")
+		}
+	case RateControlCappedCRF:
+		if p.CRF <= 0 {
+			return errors.New("capped_crf without CRF")
+		}
+		if p.Bitrate == "" && p.Maxrate == "" {
+			return errors.New("capped_crf without Bitrate or Maxrate")
+		}
+	case RateControlTwoPass:
+		if p.Bitrate == "" {
+			return errors.New("2pass without Bitrate")
+		}
+		if p.Codec == "libsvtav1" {
+			return fmt.Errorf("2pass isn't supported by %s", p.Codec)
+		}
+	case "":
+		return errors.New("without RateControl or CRF")
+	default:
+		return fmt.Errorf("unknown rate control '%s'", p.RateControl)
+	}
+	return nil
+}
+
+// Ladder struct used to bind the renditions transcoded by the local chain
+type Ladder struct {
+	Renditions []string
+	Profiles   []RenditionProfile `ini:"-"`
+}
+
+// LadderSetting instance from ladder, the defaults are used when
+// the app.ini doesn't declare any rendition
+var LadderSetting = &Ladder{
+	Renditions: []string{"240p", "360p", "480p", "720p", "1080p"},
+	Profiles: []RenditionProfile{
+		{Name: "90p", Height: 90, Codec: "h264", Profile: "main", RateControl: RateControlCappedCRF, CRF: 20, Bitrate: "100k", GOP: 48},
+		{Name: "144p", Height: 144, Codec: "h264", Profile: "main", RateControl: RateControlCappedCRF, CRF: 20, Bitrate: "100k", GOP: 48},
+		{Name: "240p", Height: 240, Codec: "h264", Profile: "main", RateControl: RateControlCappedCRF, CRF: 20, Bitrate: "120k", GOP: 48},
+		{Name: "360p", Height: 360, Codec: "h264", Profile: "main", RateControl: RateControlCappedCRF, CRF: 20, Bitrate: "284k", Maxrate: "284k", Bufsize: "568k", GOP: 48},
+		{Name: "480p", Height: 480, Codec: "h264", Profile: "main", RateControl: RateControlCappedCRF, CRF: 20, Bitrate: "341k", Maxrate: "341k", Bufsize: "682k", GOP: 48},
+		{Name: "720p", Height: 720, Codec: "h264", Profile: "main", RateControl: RateControlCappedCRF, CRF: 20, Bitrate: "765k", Maxrate: "765k", Bufsize: "1530k", GOP: 48},
+		{Name: "1080p", Height: 1080, Codec: "h264", Profile: "main", RateControl: RateControlCappedCRF, CRF: 20, Bitrate: "1579k", Maxrate: "1579k", Bufsize: "3158k", GOP: 48},
+		{Name: "720p_hevc", Height: 720, Codec: "libx265", Profile: "main", RateControl: RateControlCappedCRF, CRF: 24, Bitrate: "530k", Maxrate: "530k", Bufsize: "1060k", GOP: 48, Container: "mp4"},
+		{Name: "1080p_hevc", Height: 1080, Codec: "libx265", Profile: "main", RateControl: RateControlCappedCRF, CRF: 24, Bitrate: "1100k", Maxrate: "1100k", Bufsize: "2200k", GOP: 48, Container: "mp4"},
+		{Name: "720p_vp9", Height: 720, Codec: "libvpx-vp9", RateControl: RateControlCappedCRF, CRF: 32, Bitrate: "500k", Maxrate: "500k", Bufsize: "1000k", GOP: 48, Container: "webm"},
+		{Name: "1080p_vp9", Height: 1080, Codec: "libvpx-vp9", RateControl: RateControlCappedCRF, CRF: 31, Bitrate: "1000k", Maxrate: "1000k", Bufsize: "2000k", GOP: 48, Container: "webm"},
+		{Name: "720p_av1", Height: 720, Codec: "libsvtav1", RateControl: RateControlCappedCRF, CRF: 36, Bitrate: "400k", Maxrate: "400k", Bufsize: "800k", GOP: 48, Container: "mp4"},
+		{Name: "1080p_av1", Height: 1080, Codec: "libsvtav1", RateControl: RateControlCappedCRF, CRF: 35, Bitrate: "800k", Maxrate: "800k", Bufsize: "1600k", GOP: 48, Container: "mp4"},
+	},
+}
+
+// Profile return the rendition profile registered with the name
+func (l *Ladder) Profile(name string) (RenditionProfile, bool) {
+	for _, p := range l.Profiles {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return RenditionProfile{}, false
+}
+
+// Enabled return the profiles listed on renditions keeping the order
+func (l *Ladder) Enabled() []RenditionProfile {
+	var profiles []RenditionProfile
+	for _, name := range l.Renditions {
+		p, ok := l.Profile(strings.TrimSpace(name))
+		if !ok {
+			log.Printf("settings.Ladder, rendition '%s' without profile", name)
+			continue
+		}
+		profiles = append(profiles, p)
+	}
+	return profiles
+}
+
+// loadLadder bind the sections [rendition.<name>] over the default profiles
+func loadLadder() error {
+	if err := mapTo("ladder", LadderSetting); err != nil {
+		return err
+	}
+
+	for _, section := range cfg.Section("rendition").ChildSections() {
+		name := strings.TrimPrefix(section.Name(), "rendition.")
+		p, _ := LadderSetting.Profile(name)
+
+		if err := section.MapTo(&p); err != nil {
+			return fmt.Errorf("Cfg.MapTo %s err: %v", section.Name(), err)
+		}
+		p.Name = name
+
+		if err := p.check(); err != nil {
+			return fmt.Errorf("settings.Ladder, rendition '%s' %v", name, err)
+		}
+
+		LadderSetting.set(p)
+	}
+	return nil
+}
+
+// set replace or append the profile
+func (l *Ladder) set(profile RenditionProfile) {
+	for idx, p := range l.Profiles {
+		if p.Name == profile.Name {
+			l.Profiles[idx] = profile
+			return
+		}
+	}
+	l.Profiles = append(l.Profiles, profile)
+}
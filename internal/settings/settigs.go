@@ -1,6 +1,7 @@
 package settings
 
 import (
+	"fmt"
 	"log"
 	"time"
 
@@ -58,6 +59,220 @@ type Livepeer struct {
 // LivepeerSetting instance  from redis
 var LivepeerSetting = &Livepeer{}
 
+// FFmpeg struct used to bind the limits of the commands, the timeouts are in seconds
+type FFmpeg struct {
+	TimeoutFactor  float64
+	MinTimeout     time.Duration
+	DefaultTimeout time.Duration
+	Retries        int
+	StderrLines    int
+}
+
+// FFmpegSetting instance from ffmpeg
+var FFmpegSetting = &FFmpeg{
+	TimeoutFactor:  4,
+	MinTimeout:     600,
+	DefaultTimeout: 21600,
+	Retries:        2,
+	StderrLines:    20,
+}
+
+// Packaging struct used to bind the streaming packaging
+type Packaging struct {
+	Mode            string
+	SegmentDuration int
+}
+
+// PackagingSetting instance from packaging
+var PackagingSetting = &Packaging{
+	Mode:            "segmented",
+	SegmentDuration: 6,
+}
+
+// PerTitle struct used to bind the complexity analysis of the source
+type PerTitle struct {
+	Enabled        bool
+	Samples        int
+	SampleDuration float64
+	CRF            int
+	MinFactor      float64
+	MaxFactor      float64
+	MaxrateFactor  float64
+}
+
+// PerTitleSetting instance from pertitle
+var PerTitleSetting = &PerTitle{
+	Samples:        3,
+	SampleDuration: 4,
+	CRF:            23,
+	MinFactor:      0.4,
+	MaxFactor:      1.6,
+	MaxrateFactor:  1.5,
+}
+
+// Loudnorm struct used to bind the EBU R128 normalization of the audio ladder,
+// Integrated is in LUFS, TruePeak in dBTP and LRA in LU
+type Loudnorm struct {
+	Enabled    bool
+	Integrated float64
+	TruePeak   float64
+	LRA        float64
+}
+
+// LoudnormSetting instance from loudnorm
+var LoudnormSetting = &Loudnorm{
+	Integrated: -23,
+	TruePeak:   -1,
+	LRA:        7,
+}
+
+// Thumbnails struct used to bind the storyboard sprites, Interval is in seconds and
+// each sprite sheet has Columns x Rows thumbnails of Width x Height
+type Thumbnails struct {
+	Interval float64
+	Width    int
+	Height   int
+	Columns  int
+	Rows     int
+}
+
+// ThumbnailsSetting instance from thumbnails
+var ThumbnailsSetting = &Thumbnails{
+	Interval: 5,
+	Width:    126,
+	Height:   73,
+	Columns:  10,
+	Rows:     10,
+}
+
+// Poster struct used to bind the selection of the poster, the candidates are spread over
+// the source and the ones out of the brightness range or below the sharpness are rejected,
+// the poster is written on each width and format below the width of the source
+type Poster struct {
+	Candidates    int
+	MinBrightness float64
+	MaxBrightness float64
+	MinSharpness  float64
+	Widths        []int
+	Formats       []string
+}
+
+// PosterSetting instance from poster
+var PosterSetting = &Poster{
+	Candidates:    12,
+	MinBrightness: 0.1,
+	MaxBrightness: 0.9,
+	MinSharpness:  30,
+	Widths:        []int{320, 640, 1280},
+	Formats:       []string{"jpg", "webp", "avif"},
+}
+
+// Preview struct used to bind the animated preview clip made of Segments highlights,
+// the durations are in seconds and the clip fits on MaxWidth x MaxHeight
+type Preview struct {
+	Enabled         bool
+	GIF             bool
+	Segments        int
+	SegmentDuration float64
+	MaxDuration     float64
+	MaxWidth        int
+	MaxHeight       int
+	FPS             int
+}
+
+// PreviewSetting instance from preview
+var PreviewSetting = &Preview{
+	Enabled:         true,
+	Segments:        4,
+	SegmentDuration: 1.5,
+	MaxDuration:     6,
+	MaxWidth:        384,
+	MaxHeight:       216,
+	FPS:             12,
+}
+
+// BIF struct used to bind the roku trick-play archives written at SD and HD sizes,
+// the frames are taken at the interval of the thumbnails
+type BIF struct {
+	Enabled  bool
+	SDWidth  int
+	SDHeight int
+	HDWidth  int
+	HDHeight int
+}
+
+// BIFSetting instance from bif
+var BIFSetting = &BIF{
+	Enabled:  true,
+	SDWidth:  240,
+	SDHeight: 136,
+	HDWidth:  320,
+	HDHeight: 180,
+}
+
+// Validation struct used to bind the checks made after each rendition, the tolerances are in seconds
+type Validation struct {
+	Enabled           bool
+	Decode            bool
+	DurationTolerance float64
+	KeyframeTolerance float64
+}
+
+// ValidationSetting instance from validation
+var ValidationSetting = &Validation{
+	Enabled:           true,
+	Decode:            true,
+	DurationTolerance: 0.5,
+	KeyframeTolerance: 0.1,
+}
+
+// Chunks struct used to bind the chunked transcoding, the sources longer than MinDuration are
+// cut at their keyframes in chunks of about Duration seconds encoded in parallel by the workers
+type Chunks struct {
+	Enabled     bool
+	Duration    float64
+	MinDuration float64
+}
+
+// ChunksSetting instance from chunks
+var ChunksSetting = &Chunks{
+	Enabled:     false,
+	Duration:    60,
+	MinDuration: 600,
+}
+
+// Admission struct used to bind the rules a source must follow to enter the chain, MaxDuration
+// and DecodeDuration are in seconds, MaxSize in megabytes, the size limits apply to the landscape
+// orientation and the zero values or empty lists disable the rule
+type Admission struct {
+	Enabled        bool
+	MaxDuration    float64
+	MaxSize        int64
+	MaxWidth       int
+	MaxHeight      int
+	MinProbeScore  int
+	Containers     []string
+	VideoCodecs    []string
+	AudioCodecs    []string
+	Decode         bool
+	DecodeDuration float64
+}
+
+// AdmissionSetting instance from admission
+var AdmissionSetting = &Admission{
+	Enabled:        true,
+	MaxDuration:    14400,
+	MaxSize:        20480,
+	MaxWidth:       4096,
+	MaxHeight:      2160,
+	MinProbeScore:  50,
+	Containers:     []string{"mov", "mp4", "matroska", "webm", "avi", "mpegts", "flv", "mxf"},
+	VideoCodecs:    []string{"h264", "hevc", "vp8", "vp9", "av1", "mpeg4", "mpeg2video", "prores", "dnxhd"},
+	AudioCodecs:    []string{"aac", "mp3", "mp2", "opus", "vorbis", "ac3", "eac3", "dts", "truehd", "flac", "alac", "pcm_s16le", "pcm_s16be", "pcm_s24le", "pcm_s24be", "pcm_s32le", "pcm_f32le", "pcm_bluray", "pcm_dvd"},
+	Decode:         true,
+	DecodeDuration: 5,
+}
+
 // Redis struct used to bind redis
 type Redis struct {
 	Host                   string
@@ -87,26 +302,62 @@ var InfluxdbSetting = &Influxdb{}
 
 // Setup initialize the configuration instance
 func Setup() {
+	if err := Load("conf/app.ini"); err != nil {
+		log.Fatalf("setting.Setup, fail to parse 'conf/app.ini': %v", err)
+	}
+}
+
+// Load bind the sections of the file over the default settings
+func Load(filename string) error {
 	var err error
-	cfg, err = ini.Load("conf/app.ini")
+	cfg, err = ini.Load(filename)
 	if err != nil {
-		log.Fatalf("setting.Setup, fail to parse 'conf/app.ini': %v", err)
+		return err
 	}
 
-	mapTo("app", AppSetting)
-	mapTo("server", ServerSetting)
-	mapTo("redis", RedisSetting)
-	mapTo("ipfs", IPFSSetting)
-	mapTo("influxdb", InfluxdbSetting)
-	mapTo("livepeer", LivepeerSetting)
+	sections := []struct {
+		name string
+		v    interface{}
+	}{
+		{"app", AppSetting},
+		{"server", ServerSetting},
+		{"redis", RedisSetting},
+		{"ipfs", IPFSSetting},
+		{"influxdb", InfluxdbSetting},
+		{"livepeer", LivepeerSetting},
+		{"ffmpeg", FFmpegSetting},
+		{"packaging", PackagingSetting},
+		{"pertitle", PerTitleSetting},
+		{"loudnorm", LoudnormSetting},
+		{"thumbnails", ThumbnailsSetting},
+		{"bif", BIFSetting},
+		{"poster", PosterSetting},
+		{"preview", PreviewSetting},
+		{"validation", ValidationSetting},
+		{"admission", AdmissionSetting},
+		{"chunks", ChunksSetting},
+	}
+	for _, s := range sections {
+		if err := mapTo(s.name, s.v); err != nil {
+			return err
+		}
+	}
+
+	if err := loadLadder(); err != nil {
+		return err
+	}
+	if err := loadAudioLadder(); err != nil {
+		return err
+	}
 
 	RedisSetting.IdleTimeout = RedisSetting.IdleTimeout * time.Second
+	return nil
 }
 
 // mapTo map section
-func mapTo(section string, v interface{}) {
-	err := cfg.Section(section).MapTo(v)
-	if err != nil {
-		log.Fatalf("Cfg.MapTo %s err: %v", section, err)
+func mapTo(section string, v interface{}) error {
+	if err := cfg.Section(section).MapTo(v); err != nil {
+		return fmt.Errorf("Cfg.MapTo %s err: %v", section, err)
 	}
+	return nil
 }
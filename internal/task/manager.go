@@ -14,14 +14,14 @@ import (
 )
 
 // ManagerTranscoder managment of the task
-func ManagerTranscoder(kind, resourceID, resourceName, directory, tracker string, server *machinery.Server) error {
+func ManagerTranscoder(kind, resourceID, resourceName, directory, tracker, packaging, poster string, server *machinery.Server) error {
 	var err error
 	if kind == "remote" {
 		log.Println("not available")
 	}
 
 	if kind == "local" {
-		Local(resourceID, resourceName, directory, tracker, server)
+		_, err = Local(resourceID, resourceName, directory, tracker, packaging, poster, server)
 	}
 
 	return err
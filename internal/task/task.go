@@ -2,10 +2,14 @@ package task
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log"
 	"os"
+	"path/filepath"
 	"time"
 
+	"github.com/RichardKnop/machinery/v1/tasks"
 	"github.com/Voodfy/voodfy-transcoder/internal/ffmpeg"
 	"github.com/Voodfy/voodfy-transcoder/internal/models"
 	"github.com/Voodfy/voodfy-transcoder/internal/settings"
@@ -22,50 +26,192 @@ import (
 
 var cl = ffmpeg.NewClient()
 
-// ConvertToMp4Task ...
-func ConvertToMp4Task(args ...string) error {
-	ffmpeg.Run(&cl, "convertToMp4", args...)
+// retryHeader header of the signature counting the retries of the killed ffmpeg jobs
+const retryHeader = "ffmpeg_retries"
 
-	return nil
+// retryDelay wait before each retry, multiplied by the number of the retry
+const retryDelay = 30 * time.Second
+
+// run execute the ffmpeg job limited by its timeout, the *ffmpeg.Error is returned
+// so machinery marks the task failed unless the job was killed and can be retried
+func run(ctx context.Context, fnc string, args ...string) error {
+	timeoutCtx, cancel := context.WithTimeout(ctx, ffmpeg.Timeout(ctx, fnc, args...))
+	defer cancel()
+
+	err := ffmpeg.Run(timeoutCtx, &cl, fnc, args...)
+	utils.SendError(fmt.Sprintf("task.%s", fnc), err)
+
+	return retry(ctx, err)
 }
 
-// FFprobeTask ...
-func FFprobeTask(args ...string) error {
-	ffmpeg.Run(&cl, "FFprobe", args...)
+// retry return the error that sends the task back to the queue when the job timed out or
+// was killed and the retries of the ffmpeg setting aren't over, the other errors are kept
+// because a corrupted source or a missing encoder fails again
+func retry(ctx context.Context, err error) error {
+	var e *ffmpeg.Error
+	if !errors.Is(err, ffmpeg.ErrTimeout) && !(errors.As(err, &e) && e.Kind == ffmpeg.KindKilled) {
+		return err
+	}
 
-	return nil
+	signature := tasks.SignatureFromContext(ctx)
+	if signature == nil {
+		return err
+	}
+	if signature.Headers == nil {
+		signature.Headers = tasks.Headers{}
+	}
+
+	// the headers are decoded from json when the task comes back from the broker
+	var retries int
+	switch n := signature.Headers[retryHeader].(type) {
+	case int:
+		retries = n
+	case float64:
+		retries = int(n)
+	}
+	if retries >= settings.FFmpegSetting.Retries {
+		return err
+	}
+
+	signature.Headers[retryHeader] = retries + 1
+	return tasks.NewErrRetryTaskLater(err.Error(), time.Duration(retries+1)*retryDelay)
 }
 
-// RemoveAudioFromMp4Task ...
-func RemoveAudioFromMp4Task(args ...string) error {
-	ffmpeg.Run(&cl, "RemoveAudioFromMp4", args...)
+// ConvertToMp4Task ...
+func ConvertToMp4Task(ctx context.Context, args ...string) error {
+	return run(ctx, "convertToMp4", args...)
+}
 
-	return nil
+// FFprobeTask ...
+func FFprobeTask(ctx context.Context, args ...string) error {
+	return run(ctx, "FFprobe", args...)
+}
+
+// RemoveAudioFromMp4Task ...
+func RemoveAudioFromMp4Task(ctx context.Context, args ...string) error {
+	return run(ctx, "RemoveAudioFromMp4", args...)
 }
 
 // ThumbsPreviewGeneratorTask ...
-func ThumbsPreviewGeneratorTask(args ...string) error {
-	ffmpeg.Run(&cl, "ThumbsPreviewGenerator", args...)
-	return nil
+func ThumbsPreviewGeneratorTask(ctx context.Context, args ...string) error {
+	return run(ctx, "ThumbsPreviewGenerator", args...)
+}
+
+// GenerateWebpFromFrameVideoTask ...
+func GenerateWebpFromFrameVideoTask(ctx context.Context, args ...string) error {
+	return run(ctx, "GenerateWebpFromFrameVideo", args...)
+}
+
+// BIFGeneratorTask ...
+func BIFGeneratorTask(ctx context.Context, args ...string) error {
+	return run(ctx, "BIF", args...)
 }
 
 // GenerateImageFromFrameVideoTask ...
-func GenerateImageFromFrameVideoTask(args ...string) error {
-	ffmpeg.Run(&cl, "GenerateImageFromFrameVideo", args...)
-	return nil
+func GenerateImageFromFrameVideoTask(ctx context.Context, args ...string) error {
+	return run(ctx, "GenerateImageFromFrameVideo", args...)
 }
 
 // ExtractAudioFromMp4Task ...
-func ExtractAudioFromMp4Task(args ...string) error {
-	ffmpeg.Run(&cl, "ExtractAudioFromMp4", args...)
+func ExtractAudioFromMp4Task(ctx context.Context, args ...string) error {
+	return run(ctx, "ExtractAudioFromMp4", args...)
+}
 
-	return nil
+// AudioRenditionTask ...
+func AudioRenditionTask(ctx context.Context, args ...string) error {
+	return run(ctx, "AudioRendition", args...)
+}
+
+// AnalyzeLoudnessTask ...
+func AnalyzeLoudnessTask(ctx context.Context, args ...string) error {
+	return run(ctx, "AnalyzeLoudness", args...)
+}
+
+// SubtitlesTask ...
+func SubtitlesTask(ctx context.Context, args ...string) error {
+	return run(ctx, "Subtitles", args...)
 }
 
 // FallbackRenditionTask ...
-func FallbackRenditionTask(args ...string) error {
-	ffmpeg.Run(&cl, args[2], args...)
-	return nil
+func FallbackRenditionTask(ctx context.Context, args ...string) error {
+	return run(ctx, args[2], args...)
+}
+
+// ValidateRenditionTask ...
+func ValidateRenditionTask(ctx context.Context, args ...string) error {
+	return run(ctx, "ValidateRendition", args...)
+}
+
+// ChunkRenditionTask transcode a chunk, the chunks of a failed job are skipped and the last
+// chunk task to finish removes the chunks when the job failed
+func ChunkRenditionTask(ctx context.Context, args ...string) error {
+	job := models.Job{ID: args[3]}
+	job.Get()
+
+	err := fmt.Errorf("task: chunk %s of %s skipped, the job failed", args[4], args[2])
+	if !job.Failed() {
+		err = run(ctx, "ChunkRendition", args...)
+		if _, retry := err.(tasks.ErrRetryTaskLater); retry {
+			return err
+		}
+		if err != nil {
+			job.AddFailure(args[2], err)
+		}
+	}
+
+	// the chord callback isn't triggered once a chunk fails, the chunks wouldn't be joined
+	if job.FinishChunk() == len(job.Ladder)*len(job.Chunks) {
+		job.Get()
+		if job.Failed() {
+			os.RemoveAll(filepath.Dir(args[1]))
+		}
+	}
+	return err
+}
+
+// ConcatRenditionTask join the chunks of a rendition, the chain stops on a failure so the
+// chunks left are removed
+func ConcatRenditionTask(ctx context.Context, args ...string) error {
+	err := run(ctx, "ConcatRendition", args...)
+	if _, retry := err.(tasks.ErrRetryTaskLater); err != nil && !retry {
+		job := models.Job{ID: args[3]}
+		job.AddFailure(args[2], err)
+		os.RemoveAll(args[4])
+	}
+	return err
+}
+
+// SendRenditionsTask the chord callback of the chunks, send the chain that joins them
+func SendRenditionsTask(ctx context.Context, args ...string) error {
+	job := models.Job{ID: args[1]}
+	job.Get()
+
+	err := sendOutput(job, args[0], args[2])
+	if err != nil {
+		job.AddFailure("chunks", err)
+		os.RemoveAll(args[2])
+	}
+	return err
+}
+
+// AnalyzeComplexityTask ...
+func AnalyzeComplexityTask(ctx context.Context, args ...string) error {
+	return run(ctx, "AnalyzeComplexity", args...)
+}
+
+// PackageHLSTask ...
+func PackageHLSTask(ctx context.Context, args ...string) error {
+	return run(ctx, "PackageHLS", args...)
+}
+
+// PackageDASHTask ...
+func PackageDASHTask(ctx context.Context, args ...string) error {
+	return run(ctx, "PackageDASH", args...)
+}
+
+// PackageCMAFTask ...
+func PackageCMAFTask(ctx context.Context, args ...string) error {
+	return run(ctx, "PackageCMAF", args...)
 }
 
 // RenditionTask will send and receive the chunck transcoded by livepeer
@@ -97,8 +243,23 @@ func SendDirToIPFSTask(args ...string) (string, error) {
 		}
 	}
 
+	job := models.Job{ID: args[1]}
+	job.Get()
+
+	if job.Failed() {
+		err := fmt.Errorf("task: %s has invalid renditions %v", job.ID, job.Failures)
+		utils.SendError("task.SendDirToIPFSTask", err)
+		return "", err
+	}
+
 	utils.RenameToSendToIPFS(args[0], args[1])
-	send := utils.VerifyBeforeSendToIPFS(args[0])
+
+	expected := len(job.Ladder)
+	if expected == 0 {
+		expected = len(settings.LadderSetting.Enabled())
+	}
+
+	send := utils.VerifyBeforeSendToIPFS(args[0], expected)
 
 	if !send {
 		return "", err
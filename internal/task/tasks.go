@@ -2,13 +2,19 @@ package task
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"os"
+	"strconv"
 
 	"github.com/RichardKnop/machinery/v1"
 	"github.com/RichardKnop/machinery/v1/backends/result"
 	"github.com/RichardKnop/machinery/v1/tasks"
+	"github.com/Voodfy/voodfy-transcoder/internal/ffmpeg"
+	"github.com/Voodfy/voodfy-transcoder/internal/models"
+	"github.com/Voodfy/voodfy-transcoder/internal/settings"
+	"github.com/Voodfy/voodfy-transcoder/internal/utils"
 	"github.com/Voodfy/voodfy-transcoder/pkg/logging"
 	"github.com/opentracing/opentracing-go"
 )
@@ -18,11 +24,24 @@ func Get() map[string]interface{} {
 	return map[string]interface{}{
 		"long_running_task":               LongRunningTask,
 		"extractAudioFromMp4Task":         ExtractAudioFromMp4Task,
+		"audioRenditionTask":              AudioRenditionTask,
+		"analyzeLoudnessTask":             AnalyzeLoudnessTask,
+		"subtitlesTask":                   SubtitlesTask,
 		"removeAudioFromMp4Task":          RemoveAudioFromMp4Task,
 		"thumbsPreviewGeneratorTask":      ThumbsPreviewGeneratorTask,
 		"generateImageFromFrameVideoTask": GenerateImageFromFrameVideoTask,
+		"generateWebpFromFrameVideoTask":  GenerateWebpFromFrameVideoTask,
+		"bifGeneratorTask":                BIFGeneratorTask,
 		"fallbackRenditionTask":           FallbackRenditionTask,
+		"validateRenditionTask":           ValidateRenditionTask,
+		"chunkRenditionTask":              ChunkRenditionTask,
+		"concatRenditionTask":             ConcatRenditionTask,
+		"sendRenditionsTask":              SendRenditionsTask,
 		"renditionTask":                   RenditionTask,
+		"analyzeComplexityTask":           AnalyzeComplexityTask,
+		"packageHLSTask":                  PackageHLSTask,
+		"packageDASHTask":                 PackageDASHTask,
+		"packageCMAFTask":                 PackageCMAFTask,
 		"sendDirToIPFSTask":               SendDirToIPFSTask,
 		"sendDirToFilecoinTask":           SendDirToFilecoinTask,
 		"ffprobeTask":                     FFprobeTask,
@@ -30,6 +49,14 @@ func Get() map[string]interface{} {
 	}
 }
 
+// workerServer server of the worker used by the tasks that send other tasks
+var workerServer *machinery.Server
+
+// SetServer keep the server of the worker to the tasks that send other tasks
+func SetServer(server *machinery.Server) {
+	workerServer = server
+}
+
 // AsyncResultArray slice of AsyncResult
 type AsyncResultArray []result.AsyncResult
 
@@ -48,8 +75,13 @@ func Ping(server machinery.Server) {
 	}
 }
 
-// Local task to use ffmpeg
-func Local(resourceID, resourceName, directory, tracker string, server *machinery.Server) AsyncResultArray {
+// Local task to use ffmpeg, the chain isn't sent when the packaging is unknown or the source
+// is rejected by the admission
+func Local(resourceID, resourceName, directory, tracker, packaging, poster string, server *machinery.Server) (AsyncResultArray, error) {
+	if packaging != ffmpeg.PackagingSegmented && packaging != ffmpeg.PackagingCMAF {
+		return nil, fmt.Errorf("task: unknown packaging '%s', expected %s or %s", packaging, ffmpeg.PackagingSegmented, ffmpeg.PackagingCMAF)
+	}
+
 	src := fmt.Sprintf("%s/%s/", directory, tracker)
 	dstFiles := fmt.Sprintf("%s%s_ipfs/", src, resourceID)
 	os.MkdirAll(dstFiles, 0777)
@@ -73,34 +105,28 @@ func Local(resourceID, resourceName, directory, tracker string, server *machiner
 		},
 	}
 
-	extractAudioTask := tasks.Signature{
-		Name: "extractAudioFromMp4Task",
+	generateImageFromFrameVideoTask := tasks.Signature{
+		Name: "generateImageFromFrameVideoTask",
 		Args: []tasks.Arg{
 			{
 				Name:  "input",
 				Type:  "string",
-				Value: fmt.Sprintf("%s%s", src, resourceName),
+				Value: fmt.Sprintf("%s%s_without_audio.mp4", src, resourceID),
 			},
 			{
 				Name:  "output",
 				Type:  "string",
-				Value: fmt.Sprintf("%s%s_ipfs/%s_a1.m4a", src, resourceID, resourceID),
+				Value: dstFiles,
 			},
-		},
-	}
-
-	generateImageFromFrameVideoTask := tasks.Signature{
-		Name: "generateImageFromFrameVideoTask",
-		Args: []tasks.Arg{
 			{
-				Name:  "input",
+				Name:  "id",
 				Type:  "string",
-				Value: fmt.Sprintf("%s%s_without_audio.mp4", src, resourceID),
+				Value: resourceID,
 			},
 			{
-				Name:  "output",
+				Name:  "position",
 				Type:  "string",
-				Value: dstFiles,
+				Value: poster,
 			},
 		},
 	}
@@ -121,136 +147,459 @@ func Local(resourceID, resourceName, directory, tracker string, server *machiner
 		},
 	}
 
-	lowRenditionTask := tasks.Signature{
-		Name: "fallbackRenditionTask",
-		Args: []tasks.Arg{
-			{
-				Name:  "input",
-				Type:  "string",
-				Value: fmt.Sprintf("%s%s", src, resourceName),
-			},
-			{
-				Name:  "output",
-				Type:  "string",
-				Value: fmt.Sprintf("%s%s_v3.mp4", dstFiles, resourceID),
-			},
-			{
-				Name:  "fnc",
-				Type:  "string",
-				Value: "240p",
-			},
-		},
+	job := models.Job{
+		ID:        resourceID,
+		Source:    fmt.Sprintf("%s%s", src, resourceName),
+		Packaging: packaging,
 	}
+	job.Reset()
 
-	standardRenditionTask := tasks.Signature{
-		Name: "fallbackRenditionTask",
-		Args: []tasks.Arg{
-			{
-				Name:  "input",
-				Type:  "string",
-				Value: fmt.Sprintf("%s%s", src, resourceName),
-			},
-			{
-				Name:  "output",
-				Type:  "string",
-				Value: fmt.Sprintf("%s%s_v4.mp4", dstFiles, resourceID),
-			},
-			{
-				Name:  "fnc",
-				Type:  "string",
-				Value: "360p",
-			},
-		},
+	probe := ffmpeg.Probe
+	if settings.AdmissionSetting.Enabled {
+		probe = ffmpeg.Admit
 	}
 
-	midRenditionTask := tasks.Signature{
-		Name: "fallbackRenditionTask",
-		Args: []tasks.Arg{
-			{
-				Name:  "input",
-				Type:  "string",
-				Value: fmt.Sprintf("%s%s", src, resourceName),
+	info, err := probe(context.Background(), job.Source)
+	if errors.Is(err, ffmpeg.ErrRejected) {
+		job.Rejection = err.Error()
+		job.Save()
+		utils.SendError("task.Local.ffmpeg.Admit", err)
+		return nil, err
+	}
+	utils.SendError("task.Local.ffmpeg.Probe", err)
+
+	job.Ladder = planLadder(info, err)
+	job.Streams = ffmpeg.AudioStreams(info)
+	// when the probe fails the first audio stream is expected and the task reports the failure
+	if err != nil {
+		job.Streams = []models.AudioStream{{Language: ffmpeg.UndefinedLanguage, Default: true}}
+	}
+	if len(job.Streams) > 0 {
+		job.Audio = settings.AudioLadderSetting.Enabled()
+	}
+	job.Subtitles = ffmpeg.PlanSubtitles(info, job.Source, job.ID)
+
+	chunksDir := fmt.Sprintf("%s%s_chunks/", src, resourceID)
+	if settings.ChunksSetting.Enabled {
+		var errChunks error
+		if job.Chunks, errChunks = planChunks(job.Source, info, err); errChunks != nil {
+			log.Println(fmt.Sprintf("chunks skipped, the source is transcoded whole: %s", errChunks.Error()))
+		} else {
+			os.MkdirAll(chunksDir, 0777)
+		}
+	}
+	job.Save()
+
+	signatures := []*tasks.Signature{&removeAudioTask}
+	signatures = append(signatures, audioRenditionTasks(job, dstFiles)...)
+	signatures = append(signatures, &generateImageFromFrameVideoTask, &thumbsPreviewTask)
+
+	if settings.PreviewSetting.Enabled {
+		generateWebpFromFrameVideoTask := tasks.Signature{
+			Name: "generateWebpFromFrameVideoTask",
+			Args: []tasks.Arg{
+				{
+					Name:  "input",
+					Type:  "string",
+					Value: fmt.Sprintf("%s%s_without_audio.mp4", src, resourceID),
+				},
+				{
+					Name:  "output",
+					Type:  "string",
+					Value: dstFiles,
+				},
 			},
-			{
-				Name:  "output",
-				Type:  "string",
-				Value: fmt.Sprintf("%s%s_v5.mp4", dstFiles, resourceID),
+		}
+		signatures = append(signatures, &generateWebpFromFrameVideoTask)
+	}
+
+	if settings.BIFSetting.Enabled {
+		bifTask := tasks.Signature{
+			Name: "bifGeneratorTask",
+			Args: []tasks.Arg{
+				{
+					Name:  "input",
+					Type:  "string",
+					Value: job.Source,
+				},
+				{
+					Name:  "output",
+					Type:  "string",
+					Value: dstFiles,
+				},
+				{
+					Name:  "id",
+					Type:  "string",
+					Value: job.ID,
+				},
 			},
-			{
-				Name:  "fnc",
-				Type:  "string",
-				Value: "480p",
+		}
+		signatures = append(signatures, &bifTask)
+	}
+
+	if len(job.Subtitles) > 0 {
+		subtitlesTask := tasks.Signature{
+			Name: "subtitlesTask",
+			Args: []tasks.Arg{
+				{
+					Name:  "input",
+					Type:  "string",
+					Value: job.Source,
+				},
+				{
+					Name:  "output",
+					Type:  "string",
+					Value: dstFiles,
+				},
+				{
+					Name:  "id",
+					Type:  "string",
+					Value: job.ID,
+				},
 			},
-		},
+		}
+		signatures = append(signatures, &subtitlesTask)
 	}
 
-	hdRenditionTask := tasks.Signature{
-		Name: "fallbackRenditionTask",
-		Args: []tasks.Arg{
-			{
-				Name:  "input",
-				Type:  "string",
-				Value: fmt.Sprintf("%s%s", src, resourceName),
+	if settings.PerTitleSetting.Enabled {
+		analyzeComplexityTask := tasks.Signature{
+			Name: "analyzeComplexityTask",
+			Args: []tasks.Arg{
+				{
+					Name:  "input",
+					Type:  "string",
+					Value: job.Source,
+				},
+				{
+					Name:  "id",
+					Type:  "string",
+					Value: job.ID,
+				},
 			},
-			{
-				Name:  "output",
-				Type:  "string",
-				Value: fmt.Sprintf("%s%s_v6.mp4", dstFiles, resourceID),
+		}
+		signatures = append(signatures, &analyzeComplexityTask)
+	}
+
+	var last *tasks.Signature
+	if len(job.Chunks) > 0 {
+		last = &tasks.Signature{
+			Name: "sendRenditionsTask",
+			Args: []tasks.Arg{
+				{
+					Name:  "output",
+					Type:  "string",
+					Value: dstFiles,
+				},
+				{
+					Name:  "id",
+					Type:  "string",
+					Value: job.ID,
+				},
+				{
+					Name:  "chunks",
+					Type:  "string",
+					Value: chunksDir,
+				},
 			},
-			{
-				Name:  "fnc",
-				Type:  "string",
-				Value: "720p",
+		}
+		err = sendChunked(server, signatures, chunkTasks(job, dstFiles, chunksDir), last)
+	} else {
+		next := outputTasks(job, dstFiles, chunksDir)
+		last = next[len(next)-1]
+
+		var chain *tasks.Chain
+		chain, err = tasks.NewChain(append(signatures, next...)...)
+		if err != nil {
+			log.Panic(err)
+		}
+		_, err = server.SendChain(chain)
+	}
+
+	if err != nil {
+		log.Panic(err)
+	}
+
+	ipfs := result.NewAsyncResult(last, server.GetBackend())
+
+	var a AsyncResultArray
+	a = append(a, *ipfs)
+
+	if err != nil {
+		log.Println(fmt.Sprintf("Could not send task: %s", err.Error()))
+	}
+
+	return a, nil
+}
+
+// planLadder return the renditions enabled that fit on the probed source,
+// when the probe fails the whole ladder is used
+func planLadder(info models.MediaInfo, err error) []settings.RenditionProfile {
+	if err != nil {
+		return settings.LadderSetting.Enabled()
+	}
+
+	return ffmpeg.PlanLadder(info, settings.LadderSetting.Enabled())
+}
+
+// audioRenditionTasks return an audioRenditionTask to each audio rendition and stream planned to the job,
+// the outputs are named <id>_a<profile>_<stream>.m4a to be found by the packaging, the loudness is
+// measured before them when the normalization is enabled
+func audioRenditionTasks(job models.Job, dstFiles string) []*tasks.Signature {
+	var signatures []*tasks.Signature
+
+	if settings.LoudnormSetting.Enabled && len(job.Audio) > 0 {
+		signatures = append(signatures, &tasks.Signature{
+			Name: "analyzeLoudnessTask",
+			Args: []tasks.Arg{
+				{
+					Name:  "input",
+					Type:  "string",
+					Value: job.Source,
+				},
+				{
+					Name:  "id",
+					Type:  "string",
+					Value: job.ID,
+				},
 			},
+		})
+	}
+
+	for idx, p := range job.Audio {
+		for _, s := range job.Streams {
+			signatures = append(signatures, &tasks.Signature{
+				Name: "audioRenditionTask",
+				Args: []tasks.Arg{
+					{
+						Name:  "input",
+						Type:  "string",
+						Value: job.Source,
+					},
+					{
+						Name:  "output",
+						Type:  "string",
+						Value: fmt.Sprintf("%s%s_a%d_%d.m4a", dstFiles, job.ID, idx+1, s.Index),
+					},
+					{
+						Name:  "fnc",
+						Type:  "string",
+						Value: p.Name,
+					},
+					{
+						Name:  "id",
+						Type:  "string",
+						Value: job.ID,
+					},
+					{
+						Name:  "stream",
+						Type:  "string",
+						Value: strconv.Itoa(s.Index),
+					},
+				},
+			})
+		}
+	}
+
+	return signatures
+}
+
+// renditionArgs return the arguments of the rendition tasks, the renditions are named <id>_v<n>
+func renditionArgs(job models.Job, dstFiles string, idx int, p settings.RenditionProfile) []tasks.Arg {
+	return []tasks.Arg{
+		{
+			Name:  "input",
+			Type:  "string",
+			Value: job.Source,
+		},
+		{
+			Name:  "output",
+			Type:  "string",
+			Value: fmt.Sprintf("%s%s_v%d.%s", dstFiles, job.ID, idx+3, p.Extension()),
+		},
+		{
+			Name:  "fnc",
+			Type:  "string",
+			Value: p.Name,
+		},
+		{
+			Name:  "id",
+			Type:  "string",
+			Value: job.ID,
 		},
 	}
+}
 
-	ultraHdRenditionTask := tasks.Signature{
-		Name: "fallbackRenditionTask",
-		Args: []tasks.Arg{
-			{
-				Name:  "input",
-				Type:  "string",
-				Value: fmt.Sprintf("%s%s", src, resourceName),
-			},
-			{
-				Name:  "output",
-				Type:  "string",
-				Value: fmt.Sprintf("%s%s_v7.mp4", dstFiles, resourceID),
-			},
-			{
-				Name:  "fnc",
+// outputTasks return the tasks that transcode or join the renditions planned to the job
+// followed by the packaging
+func outputTasks(job models.Job, dstFiles, chunksDir string) []*tasks.Signature {
+	return append(renditionTasks(job, dstFiles, chunksDir), packagingTasks(dstFiles, job.ID, job.Packaging)...)
+}
+
+// renditionTasks return a fallbackRenditionTask to each rendition planned to the job followed
+// by its validateRenditionTask when the validation is enabled, the chunked jobs join the chunks
+// of the rendition with a concatRenditionTask instead of transcoding it
+func renditionTasks(job models.Job, dstFiles, chunksDir string) []*tasks.Signature {
+	var signatures []*tasks.Signature
+
+	for idx, p := range job.Ladder {
+		args := renditionArgs(job, dstFiles, idx, p)
+
+		if len(job.Chunks) > 0 {
+			signatures = append(signatures, &tasks.Signature{
+				Name: "concatRenditionTask",
+				Args: append(append([]tasks.Arg{}, args...), tasks.Arg{
+					Name:  "chunks",
+					Type:  "string",
+					Value: chunksDir,
+				}),
+			})
+		} else {
+			signatures = append(signatures, &tasks.Signature{
+				Name: "fallbackRenditionTask",
+				Args: args,
+			})
+		}
+
+		if settings.ValidationSetting.Enabled {
+			signatures = append(signatures, &tasks.Signature{
+				Name: "validateRenditionTask",
+				Args: append([]tasks.Arg{}, args...),
+			})
+		}
+	}
+
+	return signatures
+}
+
+// chunkTasks return a chunkRenditionTask to each chunk of each rendition planned to the job
+func chunkTasks(job models.Job, dstFiles, chunksDir string) []*tasks.Signature {
+	var signatures []*tasks.Signature
+
+	for idx, p := range job.Ladder {
+		args := renditionArgs(job, dstFiles, idx, p)
+		output := args[1].Value.(string)
+
+		for _, c := range job.Chunks {
+			chunkArgs := append([]tasks.Arg{}, args...)
+			chunkArgs[1].Value = ffmpeg.ChunkFile(chunksDir, output, c.Index)
+			chunkArgs = append(chunkArgs, tasks.Arg{
+				Name:  "chunk",
 				Type:  "string",
-				Value: "1080p",
-			},
-		},
+				Value: strconv.Itoa(c.Index),
+			})
+
+			signatures = append(signatures, &tasks.Signature{
+				Name: "chunkRenditionTask",
+				Args: chunkArgs,
+			})
+		}
 	}
 
-	chain, err := tasks.NewChain(
-		&removeAudioTask, &extractAudioTask,
-		&generateImageFromFrameVideoTask, &thumbsPreviewTask, &lowRenditionTask,
-		&standardRenditionTask, &midRenditionTask, &hdRenditionTask, &ultraHdRenditionTask)
+	return signatures
+}
 
+// planChunks return the chunks of the source cut at its keyframes, the error tells why the
+// source isn't chunked when it wasn't probed, it is short, its keyframes can't be read or it
+// fits on a single chunk
+func planChunks(source string, info models.MediaInfo, err error) ([]models.Chunk, error) {
 	if err != nil {
-		log.Panic(err)
+		return nil, fmt.Errorf("the source wasn't probed: %w", err)
 	}
 
-	_, err = server.SendChain(chain)
+	if info.Duration.Seconds() <= settings.ChunksSetting.MinDuration {
+		return nil, fmt.Errorf("the duration %s isn't longer than %gs", info.Duration, settings.ChunksSetting.MinDuration)
+	}
 
+	keyframes, err := cl.Keyframes(context.Background(), source)
+	utils.SendError("task.Local.ffmpeg.Keyframes", err)
 	if err != nil {
-		log.Panic(err)
+		return nil, err
 	}
 
-	ipfs := result.NewAsyncResult(&ultraHdRenditionTask, server.GetBackend())
+	chunks := ffmpeg.PlanChunks(keyframes, info, settings.ChunksSetting.Duration)
+	if len(chunks) < 2 {
+		return nil, fmt.Errorf("the %d keyframes fit on a single chunk", len(keyframes))
+	}
+	return chunks, nil
+}
 
-	var a AsyncResultArray
-	a = append(a, *ipfs)
+// sendChunked send the chain of the signatures and, once it succeeds, the chunks as a group
+// encoded in parallel by the workers, the chord callback sends the renditions chain so each
+// chunk only carries the small callback, the group is stored before the chain so the workers
+// find it when the chunks complete
+func sendChunked(server *machinery.Server, signatures, chunks []*tasks.Signature, callback *tasks.Signature) error {
+	group, err := tasks.NewGroup(chunks...)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tasks.NewChord(group, callback); err != nil {
+		return err
+	}
 
+	chain, err := tasks.NewChain(signatures...)
 	if err != nil {
-		log.Println(fmt.Sprintf("Could not send task: %s", err.Error()))
+		return err
+	}
+	signatures[len(signatures)-1].OnSuccess = group.Tasks
+
+	backend := server.GetBackend()
+	if err := backend.InitGroup(group.GroupUUID, group.GetUUIDs()); err != nil {
+		return err
+	}
+	for _, s := range group.Tasks {
+		if err := backend.SetStatePending(s); err != nil {
+			return err
+		}
+	}
+
+	_, err = server.SendChain(chain)
+	return err
+}
+
+// sendOutput send the chain that joins the chunks of the renditions planned to the job and
+// packages them with the server of the worker
+func sendOutput(job models.Job, dstFiles, chunksDir string) error {
+	if workerServer == nil {
+		return errors.New("task: without the server of the worker")
+	}
+
+	chain, err := tasks.NewChain(outputTasks(job, dstFiles, chunksDir)...)
+	if err != nil {
+		return err
+	}
+
+	_, err = workerServer.SendChain(chain)
+	return err
+}
+
+// packagingTasks return the tasks that package the renditions on the mode choosen to the job
+func packagingTasks(dstFiles, resourceID, packaging string) []*tasks.Signature {
+	names := []string{"packageHLSTask", "packageDASHTask"}
+	if packaging == ffmpeg.PackagingCMAF {
+		names = []string{"packageCMAFTask"}
+	}
+
+	var signatures []*tasks.Signature
+	for _, name := range names {
+		signatures = append(signatures, &tasks.Signature{
+			Name: name,
+			Args: []tasks.Arg{
+				{
+					Name:  "output",
+					Type:  "string",
+					Value: dstFiles,
+				},
+				{
+					Name:  "id",
+					Type:  "string",
+					Value: resourceID,
+				},
+			},
+		})
 	}
 
-	return a
+	return signatures
 }
 
 // IPFSAddDir send the directory to ipfs
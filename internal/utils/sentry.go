@@ -1,14 +1,32 @@
 package utils
 
 import (
+	"errors"
+
 	"github.com/Voodfy/voodfy-transcoder/pkg/logging"
 	"github.com/getsentry/sentry-go"
 )
 
+// extraError error that carry context to sentry
+type extraError interface {
+	Extra() map[string]interface{}
+}
+
 // SendError send error to sentry
 func SendError(function string, err error) {
 	if err != nil {
 		logging.Error(function, err.Error())
-		sentry.CaptureException(err)
+
+		var e extraError
+		if !errors.As(err, &e) {
+			sentry.CaptureException(err)
+			return
+		}
+
+		sentry.WithScope(func(scope *sentry.Scope) {
+			scope.SetTag("function", function)
+			scope.SetExtras(e.Extra())
+			sentry.CaptureException(err)
+		})
 	}
 }
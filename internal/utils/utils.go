@@ -5,39 +5,87 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
+	"unicode"
 )
 
-// RenameToSendToIPFS rename all videos to send to ipfs
+// videoExtensions containers used by the renditions
+var videoExtensions = map[string]bool{
+	".mp4":  true,
+	".webm": true,
+}
+
+// RenameToSendToIPFS rename all videos to send to ipfs, the videos are numbered from _v2 in the
+// natural order of their names so _v10 follows _v9, the renames go through temporary names so
+// a video isn't replaced by another one before it is moved
 func RenameToSendToIPFS(path, resourceID string) {
-	var idx int
-	idx = 2
 	entries, err := ioutil.ReadDir(path)
 	SendError("utils.RenameToSendToIPFS.ioutil.ReadDir", err)
+
+	var names []string
 	for _, entry := range entries {
-		extension := filepath.Ext(entry.Name())
-		if extension == ".mp4" {
-			sourcePath := filepath.Join(path, entry.Name())
-			newPath := filepath.Join(path, fmt.Sprintf("%s_v%d.mp4", resourceID, idx))
-			err := os.Rename(sourcePath, newPath)
-			SendError("os.Rename", err)
-			idx++
+		if videoExtensions[filepath.Ext(entry.Name())] {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.SliceStable(names, func(i, j int) bool { return naturalLess(names[i], names[j]) })
+
+	temporary := make([]string, len(names))
+	for idx, name := range names {
+		temporary[idx] = filepath.Join(path, fmt.Sprintf(".rename_%d%s", idx, filepath.Ext(name)))
+		err := os.Rename(filepath.Join(path, name), temporary[idx])
+		SendError("os.Rename", err)
+	}
+
+	for idx, name := range names {
+		newPath := filepath.Join(path, fmt.Sprintf("%s_v%d%s", resourceID, idx+2, filepath.Ext(name)))
+		err := os.Rename(temporary[idx], newPath)
+		SendError("os.Rename", err)
+	}
+}
+
+// naturalLess compare the names taking their runs of digits as numbers
+func naturalLess(a, b string) bool {
+	for a != "" && b != "" {
+		ra, rb := leadingRun(a), leadingRun(b)
+		if ra != rb {
+			na, errA := strconv.Atoi(ra)
+			nb, errB := strconv.Atoi(rb)
+			if errA == nil && errB == nil && na != nb {
+				return na < nb
+			}
+			return ra < rb
+		}
+		a, b = a[len(ra):], b[len(rb):]
+	}
+	return len(a) < len(b)
+}
+
+// leadingRun return the first run of digits or of other characters of the name
+func leadingRun(s string) string {
+	digit := unicode.IsDigit(rune(s[0]))
+	for idx, c := range s {
+		if unicode.IsDigit(c) != digit {
+			return s[:idx]
 		}
 	}
+	return s
 }
 
-// VerifyBeforeSendToIPFS verify if has the necessary to send to ipfs
-func VerifyBeforeSendToIPFS(path string) bool {
+// VerifyBeforeSendToIPFS verify if has the renditions expected to send to ipfs
+func VerifyBeforeSendToIPFS(path string, expected int) bool {
 	var hasExtension int
 	entries, err := ioutil.ReadDir(path)
 	SendError("utils.VerifyBeforeSendToIPFS.ioutil.ReadDir", err)
 	for _, entry := range entries {
 		extension := filepath.Ext(entry.Name())
-		if extension == ".mp4" {
+		if videoExtensions[extension] {
 			hasExtension++
 		}
 	}
 
-	if hasExtension >= 5 {
+	if hasExtension >= expected {
 		return true
 	}
 	return false
@@ -0,0 +1,114 @@
+package utils
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRenameToSendToIPFS(t *testing.T) {
+	// renditions returns the names from _v<first> to _v<last> with the extension
+	renditions := func(first, last int, ext string) []string {
+		var names []string
+		for idx := first; idx <= last; idx++ {
+			names = append(names, fmt.Sprintf("id_v%d%s", idx, ext))
+		}
+		return names
+	}
+
+	tests := []struct {
+		name     string
+		files    []string
+		expected map[string]string
+	}{
+		{
+			name:  "ladder of the chain",
+			files: renditions(3, 7, ".mp4"),
+			expected: map[string]string{
+				"id_v2.mp4": "id_v3.mp4",
+				"id_v3.mp4": "id_v4.mp4",
+				"id_v4.mp4": "id_v5.mp4",
+				"id_v5.mp4": "id_v6.mp4",
+				"id_v6.mp4": "id_v7.mp4",
+			},
+		},
+		{
+			name:  "more than eight renditions",
+			files: renditions(3, 13, ".mp4"),
+			expected: map[string]string{
+				"id_v2.mp4":  "id_v3.mp4",
+				"id_v3.mp4":  "id_v4.mp4",
+				"id_v4.mp4":  "id_v5.mp4",
+				"id_v5.mp4":  "id_v6.mp4",
+				"id_v6.mp4":  "id_v7.mp4",
+				"id_v7.mp4":  "id_v8.mp4",
+				"id_v8.mp4":  "id_v9.mp4",
+				"id_v9.mp4":  "id_v10.mp4",
+				"id_v10.mp4": "id_v11.mp4",
+				"id_v11.mp4": "id_v12.mp4",
+				"id_v12.mp4": "id_v13.mp4",
+			},
+		},
+		{
+			name:  "already numbered from two",
+			files: renditions(2, 12, ".mp4"),
+			expected: map[string]string{
+				"id_v2.mp4":  "id_v2.mp4",
+				"id_v9.mp4":  "id_v9.mp4",
+				"id_v10.mp4": "id_v10.mp4",
+				"id_v12.mp4": "id_v12.mp4",
+			},
+		},
+		{
+			name:  "webm and other files",
+			files: append(renditions(8, 11, ".webm"), "id_poster.jpg", "master.m3u8"),
+			expected: map[string]string{
+				"id_v2.webm":    "id_v8.webm",
+				"id_v3.webm":    "id_v9.webm",
+				"id_v4.webm":    "id_v10.webm",
+				"id_v5.webm":    "id_v11.webm",
+				"id_poster.jpg": "id_poster.jpg",
+				"master.m3u8":   "master.m3u8",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "rename_")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+
+			// each file holds its original name
+			for _, name := range tt.files {
+				if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(name), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			RenameToSendToIPFS(dir, "id")
+
+			entries, err := ioutil.ReadDir(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(entries) != len(tt.files) {
+				t.Errorf("expected %d files, got %d", len(tt.files), len(entries))
+			}
+
+			for name, original := range tt.expected {
+				content, err := ioutil.ReadFile(filepath.Join(dir, name))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(content) != original {
+					t.Errorf("expected %s to be %s, got %s", name, original, content)
+				}
+			}
+		})
+	}
+}
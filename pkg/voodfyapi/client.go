@@ -85,6 +85,7 @@ func (c *HTTPClient) Embed(token, title, description, cid string) (Video, error)
 		"cid":         cid,
 		"ipfs":        fmt.Sprintf("https://ipfs.voodfy.com/ipfs/%s", cid),
 		"poster":      fmt.Sprintf("https://ipfs.voodfy.com/ipfs/%s/poster.jpg", cid),
+		"posters":     fmt.Sprintf("https://ipfs.voodfy.com/ipfs/%s/posters.json", cid),
 	}
 
 	header := map[string]string{
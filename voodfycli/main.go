@@ -9,6 +9,7 @@ import (
 	"github.com/RichardKnop/machinery/v1"
 	"github.com/RichardKnop/machinery/v1/config"
 	"github.com/Voodfy/voodfy-transcoder/internal/models"
+	"github.com/Voodfy/voodfy-transcoder/internal/settings"
 	"github.com/Voodfy/voodfy-transcoder/internal/task"
 	"github.com/Voodfy/voodfy-transcoder/pkg/powergate"
 	"github.com/urfave/cli"
@@ -45,6 +46,20 @@ func init() {
 	app.Author = "Voodfy"
 	app.Email = "contact@voodfy.com"
 	app.Version = "0.0.2"
+	app.Flags = []cli.Flag{
+		cli.StringFlag{
+			Name:  "config",
+			Value: "conf/app.ini",
+			Usage: "app.ini shared with the workers, the chain is planned with its ladder and settings",
+		},
+	}
+	// the chain is planned here, the settings must be the ones of the workers
+	app.Before = func(c *cli.Context) error {
+		if err := settings.Load(c.GlobalString("config")); err != nil {
+			return fmt.Errorf("voodfycli: fail to parse '%s': %v", c.GlobalString("config"), err)
+		}
+		return nil
+	}
 }
 
 func main() {
@@ -82,10 +97,45 @@ func main() {
 			Name:    "add",
 			Aliases: []string{"a"},
 			Usage:   "add a video to transcode",
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "packaging",
+					Usage: "packaging of the renditions, segmented (hls and dash) or cmaf, the Mode of [packaging] when empty",
+				},
+				cli.BoolFlag{
+					Name:  "pertitle",
+					Usage: "analyze the complexity of the source to choose the bitrates",
+				},
+				cli.StringFlag{
+					Name:  "poster",
+					Usage: "position in seconds of the poster, it is selected from the source when empty",
+				},
+				cli.BoolFlag{
+					Name:  "loudnorm",
+					Usage: "normalize the loudness of the audio to EBU R128",
+				},
+				cli.BoolFlag{
+					Name:  "chunked",
+					Usage: "cut the long sources in chunks transcoded in parallel by the workers",
+				},
+			},
 			Action: func(c *cli.Context) error {
-				task.ManagerTranscoder(c.Args().Get(0), c.Args().Get(1), c.Args().Get(2),
-					c.Args().Get(3), c.Args().Get(4), server)
-				return nil
+				if c.Bool("pertitle") {
+					settings.PerTitleSetting.Enabled = true
+				}
+				if c.Bool("loudnorm") {
+					settings.LoudnormSetting.Enabled = true
+				}
+				if c.Bool("chunked") {
+					settings.ChunksSetting.Enabled = true
+				}
+				// the app.ini is only loaded by app.Before, after the flags are built
+				packaging := c.String("packaging")
+				if packaging == "" {
+					packaging = settings.PackagingSetting.Mode
+				}
+				return task.ManagerTranscoder(c.Args().Get(0), c.Args().Get(1), c.Args().Get(2),
+					c.Args().Get(3), c.Args().Get(4), packaging, c.String("poster"), server)
 			},
 		},
 		{
@@ -121,6 +171,27 @@ func main() {
 				return nil
 			},
 		},
+		{
+			Name:    "progress",
+			Aliases: []string{"pg"},
+			Usage:   "show the progress of the renditions giving the resource id",
+			Action: func(c *cli.Context) error {
+				for _, p := range models.GetProgress(c.Args().Get(0)) {
+					log.Printf("%s: %.1f%% (fps %.1f, speed %s)", p.Task, p.Percent, p.FPS, p.Speed)
+				}
+
+				job := models.Job{ID: c.Args().Get(0)}
+				job.Get()
+				if job.Rejection != "" {
+					log.Printf("source: rejected, %s", job.Rejection)
+				}
+				for _, f := range job.Failures {
+					log.Printf("%s: invalid, %s", f.Rendition, f.Reason)
+				}
+
+				return nil
+			},
+		},
 		{
 			Name:    "storage_config",
 			Aliases: []string{"sc"},